@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// Checksums given on the command line for the archive being installed.
+var (
+	archiveSHA256 string
+	archiveSHA512 string
+)
+
+// addChecksumFlags registers the flags for commands that verify an archive before installing it.
+func addChecksumFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&archiveSHA256, "sha256", "", "expected SHA-256 checksum of the archive")
+	cmd.Flags().StringVar(&archiveSHA512, "sha512", "", "expected SHA-512 checksum of the archive")
+	cmd.MarkFlagsMutuallyExclusive("sha256", "sha512")
+}
+
+// verifyArchive checks an archive before anything is extracted from it, against the
+// checksum given with --sha256 or --sha512, the checksum listed in a repository index
+// or, failing those, one published in a SHA256SUMS or SHA512SUMS file next to it. A
+// mismatch is fatal, but a server that will not say whether it publishes a checksum file
+// only earns a warning. It returns the archive's digest and whether it was verified; without
+// an expected checksum the SHA-256 of the archive is still returned so that the installed
+// artifact can be identified.
+func verifyArchive(source, archivePath string, indexed *pkg.IndexEntry) (pkg.Digest, bool) {
+	var expected pkg.Digest
+	var origin string
+	var err error
+	switch {
+	case archiveSHA256 != "":
+		expected, err = pkg.NewDigest(pkg.SHA256, archiveSHA256)
+		origin = "--sha256"
+	case archiveSHA512 != "":
+		expected, err = pkg.NewDigest(pkg.SHA512, archiveSHA512)
+		origin = "--sha512"
+	case indexed != nil && indexed.Checksum != "":
+		expected, err = pkg.ParseDigest(indexed.Checksum)
+		origin = "the repository index"
+	default:
+		expected, origin, err = pkg.FindPublishedChecksum(source, archiveFileNames(source, archivePath), downloadTimeout)
+		var unavailable *pkg.UnavailableError
+		if errors.As(err, &unavailable) {
+			fmt.Printf("Warning: %v, so no published checksum could be found.\n", err)
+			err = nil
+		}
+		if err == nil && origin != "" && expected.Hex == "" {
+			fmt.Printf("Warning: %s is not listed in any published checksum file (checked %s).\n", filepath.Base(archivePath), origin)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if expected.Hex == "" {
+		digest, err := pkg.FileDigest(archivePath, pkg.SHA256)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("No checksum was given or published for the archive; it has not been verified.")
+		return digest, false
+	}
+
+	if err := pkg.VerifyFile(archivePath, expected); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Verified %s checksum from %s.\n", expected.Algorithm, origin)
+	return expected, true
+}
+
+// archiveFileNames returns the names an archive may be listed under in a checksum file:
+// the last element of the URL or path given by the user and, for downloads, the name it
+// was saved under.
+func archiveFileNames(source, archivePath string) []string {
+	name := filepath.Base(source)
+	if pkg.IsURL(source) {
+		if u, err := url.Parse(source); err == nil {
+			name = path.Base(u.Path)
+		}
+	}
+	if saved := filepath.Base(archivePath); saved != name {
+		return []string{name, saved}
+	}
+	return []string{name}
+}
+
+// recordArchive stores where a package was installed from and the archive's digest in its record.
+func recordArchive(p *pkg.Package, source string, digest pkg.Digest, verified bool) {
+	if !pkg.IsURL(source) {
+		if absolute, err := filepath.Abs(source); err == nil {
+			source = absolute
+		}
+	}
+	p.Source = source
+	p.ArchiveDigest = digest.String()
+	p.DigestVerified = verified
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// Flags that select the installation scope and override the configuration, shared by every command.
+var (
+	scopeUser   bool
+	scopeSystem bool
+
+	configRoot       string
+	configFile       string
+	configStoreDir   string
+	configBinDir     string
+	configDesktopDir string
+
+	lockTimeout time.Duration
+)
+
+// AddConfigFlags registers the global flags on the root command: --user and --system
+// choose between the per-user and system-wide installations, and the remaining flags
+// override the configuration file for a single invocation.
+func AddConfigFlags(rootCmd *cobra.Command) {
+	flags := rootCmd.PersistentFlags()
+	flags.BoolVar(&scopeUser, "user", false, "manage packages in your home directory (the default when not running as root)")
+	flags.BoolVar(&scopeSystem, "system", false, "manage system-wide packages (the default when running as root; requires root privileges)")
+	flags.StringVar(&configRoot, "root", "", "alternate root directory to manage packages in, e.g. an image being built (implies --system)")
+	flags.StringVar(&configFile, "config", "", "additional configuration file, read after "+pkg.SystemConfigPath+" and the user's config")
+	flags.StringVar(&configStoreDir, "store-dir", "", "directory holding installed packages and the package database")
+	flags.StringVar(&configBinDir, "bin-dir", "", "directory where executable symlinks are created")
+	flags.StringVar(&configDesktopDir, "desktop-dir", "", "directory where .desktop files are written")
+	flags.DurationVar(&lockTimeout, "lock-timeout", pkg.DefaultLockTimeout, "how long to wait for another packagemanager process to finish")
+	rootCmd.MarkFlagsMutuallyExclusive("user", "system")
+	rootCmd.MarkFlagsMutuallyExclusive("user", "root")
+}
+
+// loadConfig picks the installation scope for this invocation and loads its settings.
+// The per-user scope is used with --user or when not running as root; otherwise packages
+// are managed system-wide, which requires root privileges unless --root places them in
+// an alternate root directory. Flags override the configuration files and environment.
+func loadConfig() (*pkg.Config, error) {
+	// Populating an alternate root only needs write access to that directory.
+	canManageSystem := os.Geteuid() == 0 || configRoot != ""
+
+	scope := pkg.ScopeSystem
+	if scopeUser || (!scopeSystem && !canManageSystem) {
+		scope = pkg.ScopeUser
+	} else if !canManageSystem {
+		return nil, fmt.Errorf("you need to have root privileges to manage system-wide packages (use --user to install into your home directory)")
+	}
+
+	return pkg.LoadConfig(scope, pkg.ConfigOverrides{
+		Root:       configRoot,
+		File:       configFile,
+		StoreDir:   configStoreDir,
+		BinDir:     configBinDir,
+		DesktopDir: configDesktopDir,
+	})
+}
+
+// openPackageManager loads the configuration of the selected scope, locks its store
+// directory and loads its package database, exiting with an error message if any step
+// fails. Commands that modify packages take an exclusive lock, and read-only commands a
+// shared one, so that concurrent processes cannot overwrite each other's changes. The
+// caller releases the lock when it is done; it is also released when the process exits.
+func openPackageManager(mode pkg.LockMode) (*pkg.Config, *pkg.PackageManager, *pkg.Lock) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Lock the store before reading the database, so that it cannot change underneath us.
+	lock, err := pkg.AcquireLock(cfg.StoreDir, mode, lockTimeout)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Initialise the PackageManager, which manages the tracking of installed packages.
+	pm, err := pkg.NewPackageManagerWithLock(cfg.DatabasePath(), cfg.Root, mode)
+	if err != nil {
+		fmt.Printf("Error initialising PackageManager: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg, pm, lock
+}
+
+// runRefreshHooks runs the configured refresh hooks after packages have changed, for
+// example to make an application launcher pick up new desktop entries. Failures are
+// reported but are not fatal. Hooks are skipped when managing an alternate root, since
+// they would act on the running system rather than the image.
+func runRefreshHooks(cfg *pkg.Config) {
+	if cfg.Root != "" {
+		return
+	}
+	for _, hook := range cfg.RefreshHooks {
+		fields := strings.Fields(hook)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := exec.Command(fields[0], fields[1:]...).Run(); err != nil {
+			fmt.Printf("Warning: refresh hook %q failed: %v\n", hook, err)
+		} else {
+			fmt.Printf("Ran refresh hook: %s\n", hook)
+		}
+	}
+}
+
+// warnIfNotOnPath tells the user when the bin directory that executables are linked
+// into is not on their PATH, which is common for ~/.local/bin on a fresh account.
+func warnIfNotOnPath(binDir string) {
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(dir) == filepath.Clean(binDir) {
+			return
+		}
+	}
+	fmt.Printf("Warning: %s is not on your PATH; add it to run installed executables by name.\n", binDir)
+}
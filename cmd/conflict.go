@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// Policies for resolving conflicts, chosen with --on-conflict.
+const (
+	conflictAsk     = "ask"     // Ask on a terminal; --yes means replace.
+	conflictReplace = "replace" // Take over contested symlinks and unmanaged .desktop files.
+	conflictRename  = "rename"  // Install under an unused package name and symlink names instead.
+	conflictAbort   = "abort"   // Refuse to install.
+)
+
+// addConflictFlag registers the --on-conflict flag on a command that installs packages.
+func addConflictFlag(cmd *cobra.Command, policy *string) {
+	cmd.Flags().StringVar(policy, "on-conflict", conflictAsk, "what to do if the package collides with another package or unmanaged file: ask, replace, rename or abort")
+}
+
+// resolveConflicts checks a package that is about to be installed for collisions with
+// other packages and unmanaged files, before any symlinks, .desktop files or package
+// records are written, and applies the conflict policy. Renaming updates the package's
+// name and symlink names in place. It reports false if the user chose to abort.
+func resolveConflicts(prompt *prompter, pm *pkg.PackageManager, cfg *pkg.Config, p *pkg.Package, policy string) (bool, error) {
+	conflicts := pkg.FindConflicts(pm, cfg, *p)
+	if len(conflicts) == 0 {
+		return true, nil
+	}
+
+	fmt.Printf("Package '%s' conflicts with existing files or packages:\n", p.Name)
+	for _, conflict := range conflicts {
+		fmt.Printf("  %s\n", conflict)
+	}
+
+	policy, err := chooseConflictPolicy(prompt, policy)
+	if err != nil {
+		return false, err
+	}
+
+	switch policy {
+	case conflictAbort:
+		return false, nil
+	case conflictReplace:
+		// Only files can be taken over. Two packages sharing a name or desktop ID would
+		// both match upgrade and uninstall, and removing either would take the other's
+		// .desktop file with it.
+		for _, conflict := range conflicts {
+			if conflict.Kind == pkg.ConflictName || (conflict.Kind == pkg.ConflictDesktopID && conflict.Owner != "") {
+				return false, fmt.Errorf("replace cannot install '%s' alongside package %s under the same name or desktop ID (use --on-conflict=rename, or uninstall %s first)", p.Name, conflict.Owner, conflict.Owner)
+			}
+		}
+		fmt.Println("Replacing the conflicting files.")
+		return true, nil
+	}
+
+	// Give the package a name that is free, along with its desktop ID, then move each
+	// contested symlink to a free name.
+	for _, conflict := range conflicts {
+		if conflict.Kind == pkg.ConflictName || conflict.Kind == pkg.ConflictDesktopID {
+			name := uniquePackageName(pm, cfg, *p)
+			fmt.Printf("Installing as '%s' instead of '%s'.\n", name, p.Name)
+			p.Name = name
+			break
+		}
+	}
+	for _, conflict := range conflicts {
+		if conflict.Kind != pkg.ConflictBinLink {
+			continue
+		}
+		for _, executable := range p.LinkedExecutables() {
+			if p.SymlinkPath(cfg, executable) != conflict.Path {
+				continue
+			}
+			linkName := uniqueLinkName(cfg, filepath.Base(executable)+"-"+p.Name)
+			if p.LinkNames == nil {
+				p.LinkNames = map[string]string{}
+			}
+			p.LinkNames[filepath.Base(executable)] = linkName
+			fmt.Printf("Linking %s as %s instead.\n", filepath.Base(executable), linkName)
+		}
+	}
+
+	if remaining := pkg.FindConflicts(pm, cfg, *p); len(remaining) > 0 {
+		return false, fmt.Errorf("conflicts remain after renaming: %s", remaining[0])
+	}
+	return true, nil
+}
+
+// validateConflictPolicy checks the value of --on-conflict.
+func validateConflictPolicy(policy string) error {
+	switch policy {
+	case conflictAsk, conflictReplace, conflictRename, conflictAbort:
+		return nil
+	}
+	return fmt.Errorf("invalid --on-conflict policy %q (expected ask, replace, rename or abort)", policy)
+}
+
+// chooseConflictPolicy resolves the "ask" policy to a concrete one: --yes replaces,
+// a terminal asks the user, and non-interactive mode fails.
+func chooseConflictPolicy(prompt *prompter, policy string) (string, error) {
+	if policy != conflictAsk {
+		return policy, nil
+	}
+	if prompt.assumeYes {
+		return conflictReplace, nil
+	}
+	if !prompt.interactive {
+		return "", fmt.Errorf("conflicts found (non-interactive mode; use --on-conflict=replace, rename or abort)")
+	}
+	for {
+		answer, err := prompt.askString("Replace, rename or abort?", conflictAbort)
+		if err != nil {
+			return "", err
+		}
+		switch answer = strings.ToLower(answer); answer {
+		case conflictReplace, conflictRename, conflictAbort:
+			return answer, nil
+		}
+		fmt.Println("Please answer replace, rename or abort.")
+	}
+}
+
+// uniquePackageName returns the first of name-2, name-3, ... that no installed package
+// uses, ignoring case, and whose .desktop file does not exist.
+func uniquePackageName(pm *pkg.PackageManager, cfg *pkg.Config, p pkg.Package) string {
+	for i := 2; ; i++ {
+		name := fmt.Sprintf("%s-%d", p.Name, i)
+		taken := false
+		for _, installed := range pm.Packages {
+			if strings.EqualFold(installed.Name, name) {
+				taken = true
+				break
+			}
+		}
+		if !taken && !p.NoDesktop {
+			_, err := os.Lstat(pkg.DesktopFilePath(cfg, name))
+			taken = err == nil
+		}
+		if !taken {
+			return name
+		}
+	}
+}
+
+// uniqueLinkName returns name, or the first of name-2, name-3, ... that does not exist
+// in the bin directory.
+func uniqueLinkName(cfg *pkg.Config, name string) string {
+	candidate := name
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(filepath.Join(cfg.BinDir, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+}
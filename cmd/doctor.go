@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the doctor command.
+var (
+	doctorFix    bool
+	doctorDryRun bool
+)
+
+// DoctorCmd represents the 'doctor' command for the PackageManager.
+// It scans for state where the package database and the filesystem have diverged, such
+// as store directories left behind by failed installs, records whose installation
+// directory is gone, dangling symlinks and stale .desktop entries. With --fix it repairs
+// them in a single transaction, and with --dry-run it shows what --fix would do.
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Find and repair differences between the package database and the filesystem",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Only an actual repair needs to keep other processes out.
+		mode := pkg.SharedLock
+		if doctorFix && !doctorDryRun {
+			mode = pkg.ExclusiveLock
+		}
+
+		// Load the package database for the selected scope (per-user or system-wide).
+		cfg, pm, lock := openPackageManager(mode)
+		defer lock.Release()
+
+		issues, err := pkg.Diagnose(pm, cfg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(issues) == 0 {
+			fmt.Println("No problems found.")
+			return
+		}
+
+		// Report the issues, along with the planned repair when previewing.
+		fmt.Printf("Found %d problem(s):\n", len(issues))
+		for _, issue := range issues {
+			fmt.Printf("  %s: %s (%s)\n", issue.Kind, issue.Path, issue.Detail)
+			if doctorDryRun {
+				if repair := planRepair(pm, cfg, issue); repair.apply != nil {
+					fmt.Printf("    would %s\n", repair.action)
+				} else {
+					fmt.Printf("    cannot repair automatically: %s\n", repair.action)
+				}
+			}
+		}
+
+		if doctorDryRun {
+			fmt.Println("Dry run: no changes were made.")
+			return
+		}
+		if !doctorFix {
+			fmt.Println("Run 'packagemanager doctor --fix' to repair them, or add --dry-run to preview the repairs.")
+			os.Exit(1)
+		}
+
+		// Plan each repair just before applying it, since an earlier repair may already
+		// have resolved a later issue, e.g. removing a record also removes its symlinks.
+		tx := beginTransaction()
+		touched := map[string]bool{}
+		unresolved := 0
+		for _, issue := range issues {
+			repair := planRepair(pm, cfg, issue)
+			if repair.resolved {
+				continue
+			}
+			if repair.apply == nil {
+				fmt.Printf("Skipped %s: %s\n", issue.Path, repair.action)
+				unresolved++
+				continue
+			}
+			if err := repair.apply(tx); err != nil {
+				abortTransaction(tx, "Error: failed to %s: %v\n", repair.action, err)
+			}
+			fmt.Printf("Fixed %s: %s\n", issue.Path, repair.action)
+			if issue.Package != "" {
+				touched[issue.Package] = true
+			}
+		}
+
+		// Repaired symlinks and .desktop entries are owned by their packages again.
+		for name := range touched {
+			if err := refreshOwnedFiles(tx, pm, cfg, name); err != nil {
+				abortTransaction(tx, "Error: %v\n", err)
+			}
+		}
+		tx.Commit()
+
+		if unresolved > 0 {
+			fmt.Printf("%d problem(s) could not be repaired automatically.\n", unresolved)
+			os.Exit(1)
+		}
+		fmt.Println("All problems were repaired.")
+
+		// Run the refresh hooks, e.g. so that launchers pick up desktop entry changes.
+		runRefreshHooks(cfg)
+	},
+}
+
+func init() {
+	DoctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "repair the problems found")
+	DoctorCmd.Flags().BoolVar(&doctorDryRun, "dry-run", false, "show the repairs --fix would make without making them")
+}
+
+// repair is a planned fix for an issue. A nil apply means the issue cannot be repaired
+// automatically, and action explains why.
+type repair struct {
+	action   string
+	apply    func(tx *pkg.Transaction) error
+	resolved bool // Whether an earlier repair already fixed the issue.
+}
+
+// alreadyResolved is planned for issues that an earlier repair has fixed.
+var alreadyResolved = repair{action: "do nothing (already resolved)", apply: func(*pkg.Transaction) error { return nil }, resolved: true}
+
+// planRepair decides how to fix an issue given the current state of the database and
+// filesystem. Issues that an earlier repair has already resolved get a no-op repair.
+func planRepair(pm *pkg.PackageManager, cfg *pkg.Config, issue pkg.Issue) repair {
+	switch issue.Kind {
+	case pkg.IssueMissingInstall:
+		current := pm.FindPackage(issue.Package)
+		if current == nil || current.InstallPath != issue.Path {
+			return alreadyResolved
+		}
+		return planMissingInstall(pm, cfg, *current)
+
+	case pkg.IssueMissingGeneration:
+		current := pm.FindPackage(issue.Package)
+		if current == nil {
+			return alreadyResolved
+		}
+		return repair{
+			action: fmt.Sprintf("forget the missing generation of %s", issue.Package),
+			apply: func(tx *pkg.Transaction) error {
+				updated := *current
+				updated.Generations = nil
+				for _, generation := range current.Generations {
+					if generation.InstallPath != issue.Path {
+						updated.Generations = append(updated.Generations, generation)
+					}
+				}
+				return savePackage(tx, pm, current.UUID, updated)
+			},
+		}
+
+	case pkg.IssueOrphanedDirectory:
+		return planOrphanedDirectory(pm, cfg, issue.Path)
+
+	case pkg.IssueDanglingSymlink:
+		if !isDanglingLink(cfg, issue.Path) {
+			return alreadyResolved
+		}
+		if executable := ownedExecutable(pm, cfg, issue.Package, issue.Path); executable != "" {
+			return repair{
+				action: fmt.Sprintf("re-point the symlink at %s", cfg.ImagePath(executable)),
+				apply: func(tx *pkg.Transaction) error {
+					if err := tx.Preserve(issue.Path); err != nil {
+						return err
+					}
+					return pkg.ReplaceSymlink(cfg.ImagePath(executable), issue.Path)
+				},
+			}
+		}
+		return removeRepair("remove the symlink", issue.Path)
+
+	case pkg.IssueMissingSymlink:
+		if _, err := os.Lstat(issue.Path); err == nil {
+			return alreadyResolved
+		}
+		executable := ownedExecutable(pm, cfg, issue.Package, issue.Path)
+		if executable == "" {
+			return alreadyResolved
+		}
+		return repair{
+			action: fmt.Sprintf("re-create the symlink to %s", cfg.ImagePath(executable)),
+			apply: func(tx *pkg.Transaction) error {
+				return tx.Symlink(cfg.ImagePath(executable), issue.Path)
+			},
+		}
+
+	case pkg.IssueStaleDesktopEntry, pkg.IssueMissingDesktopEntry:
+		if executable := pkg.DesktopExecutable(issue.Path); executable != "" {
+			if _, err := os.Stat(cfg.HostPath(executable)); err == nil {
+				return alreadyResolved
+			}
+		}
+		owner := pm.FindPackage(issue.Package)
+		if owner == nil || owner.NoDesktop {
+			if _, err := os.Lstat(issue.Path); err != nil {
+				return alreadyResolved
+			}
+			return removeRepair("remove the .desktop file", issue.Path)
+		}
+		if _, err := os.Stat(owner.Executable); err != nil {
+			return removeRepair("remove the .desktop file", issue.Path)
+		}
+		p := *owner
+		return repair{
+			action: fmt.Sprintf("re-create the .desktop file for %s", p.Name),
+			apply: func(tx *pkg.Transaction) error {
+				if err := tx.Preserve(issue.Path); err != nil {
+					return err
+				}
+				return pkg.CreateDesktopFile(cfg, p.Executable, p.Name, p.InstallPath, pkg.DesktopOptionsFor(p))
+			},
+		}
+
+	case pkg.IssueLeftoverFile:
+		if _, err := os.Lstat(issue.Path); err != nil {
+			return alreadyResolved
+		}
+		return removeRepair("delete it", issue.Path)
+	}
+
+	return repair{action: "no repair is known for this problem"}
+}
+
+// planMissingInstall repairs a package whose current installation directory is gone by
+// switching to its newest retained generation that is still on disk, or by removing the
+// package record and its symlinks and .desktop entry if there is none.
+func planMissingInstall(pm *pkg.PackageManager, cfg *pkg.Config, current pkg.Package) repair {
+	var survivors []pkg.Package
+	for _, generation := range current.Generations {
+		if _, err := os.Stat(generation.InstallPath); err == nil {
+			survivors = append(survivors, generation)
+		}
+	}
+
+	if len(survivors) > 0 {
+		// Generations are kept newest first.
+		updated := survivors[0]
+		updated.Generations = survivors[1:]
+		if err := checkActivationConflicts(pm, cfg, updated); err != nil {
+			return repair{action: fmt.Sprintf("cannot switch to the retained version: %v", err)}
+		}
+		return repair{
+			action: fmt.Sprintf("switch %s to its retained version %s", current.Name, displayVersion(updated.Version)),
+			apply: func(tx *pkg.Transaction) error {
+				return activatePackage(tx, pm, cfg, current, updated)
+			},
+		}
+	}
+
+	return repair{
+		action: fmt.Sprintf("remove the record of %s", current.Name),
+		apply: func(tx *pkg.Transaction) error {
+			for _, owned := range current.ExternalFiles(cfg) {
+				if current.CheckOwnership(cfg, owned) != nil {
+					continue
+				}
+				if err := tx.Remove(owned.Path); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			if err := tx.Preserve(pm.PackagesFile); err != nil {
+				return err
+			}
+			return pm.RemovePackage(current.UUID)
+		},
+	}
+}
+
+// planOrphanedDirectory re-adopts a store directory that no record refers to as an
+// installed package, when its name and executables can be determined and nothing else
+// occupies its symlinks. Directories of a package that is already installed under another
+// directory are leftovers of an interrupted install or upgrade, and are removed.
+func planOrphanedDirectory(pm *pkg.PackageManager, cfg *pkg.Config, installPath string) repair {
+	if _, err := os.Stat(installPath); err != nil {
+		return alreadyResolved
+	}
+
+	// Store directories are named <uuid>-<name>.
+	installUUID, name := uuid.New().String(), filepath.Base(installPath)
+	if base := filepath.Base(installPath); len(base) > 37 && base[36] == '-' {
+		if _, err := uuid.Parse(base[:36]); err == nil {
+			installUUID, name = base[:36], base[37:]
+		}
+	}
+
+	manifest, packageRoot, err := pkg.LoadManifest(installPath)
+	if err != nil {
+		return repair{action: fmt.Sprintf("the directory cannot be adopted: %v", err)}
+	}
+	if manifest != nil && manifest.Name != "" {
+		name = manifest.Name
+	}
+	if pm.FindPackage(name) != nil {
+		return removeRepair(fmt.Sprintf("remove the directory (%s is installed elsewhere)", name), installPath)
+	}
+
+	var executables []string
+	if manifest != nil && len(manifest.Executables) > 0 {
+		executables = manifest.ExecutablePaths(packageRoot)
+	} else if executables, err = findExecutablesRecursively(installPath); err != nil || len(executables) != 1 {
+		return repair{action: "its executable cannot be determined; remove it by hand or reinstall the package"}
+	}
+
+	version := ""
+	if manifest != nil {
+		version = manifest.Version
+	}
+	adopted := buildPackageRecord(installUUID, name, installPath, version, executables, manifest, packageRoot)
+	for _, executable := range executables {
+		symlinkPath := adopted.SymlinkPath(cfg, executable)
+		if _, err := os.Lstat(symlinkPath); err == nil && !isDanglingLink(cfg, symlinkPath) {
+			return repair{action: fmt.Sprintf("the directory cannot be adopted because %s already exists", symlinkPath)}
+		}
+	}
+	if info, err := os.Stat(installPath); err == nil {
+		adopted.InstalledAt = info.ModTime().UTC()
+	}
+	// Leave an existing .desktop file of the same name alone.
+	if _, err := os.Lstat(pkg.DesktopFilePath(cfg, name)); err == nil {
+		adopted.NoDesktop = true
+	}
+
+	return repair{
+		action: fmt.Sprintf("adopt it as package %s", name),
+		apply: func(tx *pkg.Transaction) error {
+			for _, executable := range adopted.LinkedExecutables() {
+				symlinkPath := adopted.SymlinkPath(cfg, executable)
+				if err := tx.Preserve(symlinkPath); err != nil {
+					return err
+				}
+				if err := pkg.ReplaceSymlink(cfg.ImagePath(executable), symlinkPath); err != nil {
+					return err
+				}
+			}
+			if !adopted.NoDesktop {
+				if err := tx.Preserve(pkg.DesktopFilePath(cfg, name)); err != nil {
+					return err
+				}
+				if err := pkg.CreateDesktopFile(cfg, adopted.Executable, name, installPath, pkg.DesktopOptionsFor(adopted)); err != nil {
+					return err
+				}
+			}
+			if err := adopted.RecordOwnedFiles(cfg); err != nil {
+				return err
+			}
+			if err := tx.Preserve(pm.PackagesFile); err != nil {
+				return err
+			}
+			return pm.AddPackage(adopted)
+		},
+	}
+}
+
+// removeRepair plans the removal of a file or directory.
+func removeRepair(action, path string) repair {
+	return repair{
+		action: action,
+		apply: func(tx *pkg.Transaction) error {
+			if err := tx.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+// ownedExecutable returns the executable of the named package that is linked at
+// symlinkPath, if the package is installed and the executable exists.
+func ownedExecutable(pm *pkg.PackageManager, cfg *pkg.Config, packageName, symlinkPath string) string {
+	owner := pm.FindPackage(packageName)
+	if owner == nil {
+		return ""
+	}
+	for _, executable := range owner.LinkedExecutables() {
+		if owner.SymlinkPath(cfg, executable) != symlinkPath {
+			continue
+		}
+		if _, err := os.Stat(executable); err == nil {
+			return executable
+		}
+	}
+	return ""
+}
+
+// isDanglingLink reports whether path is a symlink into the store whose target is gone.
+func isDanglingLink(cfg *pkg.Config, path string) bool {
+	target, err := os.Readlink(path)
+	if err != nil || !strings.HasPrefix(target, cfg.ImagePath(cfg.StoreDir)+string(filepath.Separator)) {
+		return false
+	}
+	_, err = os.Stat(cfg.HostPath(target))
+	return os.IsNotExist(err)
+}
+
+// refreshOwnedFiles re-records the ownership manifest of a repaired package, so that
+// re-created symlinks and .desktop entries are owned by it again.
+func refreshOwnedFiles(tx *pkg.Transaction, pm *pkg.PackageManager, cfg *pkg.Config, name string) error {
+	current := pm.FindPackage(name)
+	if current == nil {
+		return nil
+	}
+	updated := *current
+	if err := updated.RecordOwnedFiles(cfg); err != nil {
+		return err
+	}
+	return savePackage(tx, pm, current.UUID, updated)
+}
+
+// savePackage replaces a package record, preserving the database in tx first.
+func savePackage(tx *pkg.Transaction, pm *pkg.PackageManager, packageUUID string, updated pkg.Package) error {
+	if err := tx.Preserve(pm.PackagesFile); err != nil {
+		return err
+	}
+	if err := pm.ReplacePackage(packageUUID, updated); err != nil {
+		return fmt.Errorf("error updating package record: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// downloadTimeout is how long a download may stall before it is abandoned.
+var downloadTimeout time.Duration
+
+// addDownloadFlags registers the flags for commands that accept an archive URL.
+func addDownloadFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&downloadTimeout, "download-timeout", pkg.DefaultDownloadTimeout, "give up on a download after this long without progress")
+}
+
+// fetchArchive returns the local path of an archive given on the command line. URLs are
+// downloaded into the cache first; an interrupted download resumes where it stopped when
+// the command is run again.
+func fetchArchive(cfg *pkg.Config, source string) string {
+	if !pkg.IsURL(source) {
+		return source
+	}
+
+	fmt.Printf("Downloading %s...\n", source)
+	progress := &downloadProgress{terminal: term.IsTerminal(int(os.Stdout.Fd()))}
+	archivePath, err := pkg.Download(source, pkg.DownloadOptions{
+		CacheDir: cfg.CacheDir,
+		Timeout:  downloadTimeout,
+		Progress: progress.update,
+	})
+	progress.finish()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Downloaded %s\n", archivePath)
+	return archivePath
+}
+
+// downloadProgress renders download progress on a single terminal line. When stdout is
+// not a terminal, only the final size is printed.
+type downloadProgress struct {
+	terminal    bool
+	done, total int64
+	lastUpdate  time.Time
+	shown       bool
+}
+
+// update records progress and redraws the line at most a few times per second.
+func (p *downloadProgress) update(done, total int64) {
+	p.done, p.total = done, total
+	if !p.terminal || time.Since(p.lastUpdate) < 200*time.Millisecond {
+		return
+	}
+	p.lastUpdate = time.Now()
+	p.shown = true
+	fmt.Printf("\r\033[K  %s", p.describe())
+}
+
+// finish ends the progress line.
+func (p *downloadProgress) finish() {
+	if p.shown {
+		fmt.Printf("\r\033[K  %s\n", p.describe())
+	} else if p.done > 0 {
+		fmt.Printf("  %s\n", p.describe())
+	}
+}
+
+// describe formats the progress, e.g. "3.2 MiB of 10.0 MiB (32%)".
+func (p *downloadProgress) describe() string {
+	if p.total <= 0 {
+		return formatTransferSize(p.done)
+	}
+	return fmt.Sprintf("%s of %s (%d%%)", formatTransferSize(p.done), formatTransferSize(p.total), p.done*100/p.total)
+}
+
+// formatTransferSize renders a byte count with one decimal place in the largest
+// fitting binary unit.
+func formatTransferSize(n int64) string {
+	for _, unit := range byteSizeUnits {
+		if n >= unit.multiplier {
+			return fmt.Sprintf("%.1f %siB", float64(n)/float64(unit.multiplier), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// Extraction limit flags shared by the commands that extract archives. Sizes accept an
+// optional K, M, G or T suffix (powers of 1024); "0" disables the corresponding limit.
+var (
+	extractMaxSize     string
+	extractMaxFileSize string
+	extractMaxEntries  int64
+)
+
+// addExtractLimitFlags registers the extraction limit flags on a command.
+func addExtractLimitFlags(cmd *cobra.Command) {
+	defaults := pkg.DefaultExtractLimits
+	cmd.Flags().StringVar(&extractMaxSize, "max-size", formatByteSize(defaults.MaxTotalSize), "maximum total uncompressed size of the archive")
+	cmd.Flags().StringVar(&extractMaxFileSize, "max-file-size", formatByteSize(defaults.MaxFileSize), "maximum uncompressed size of any single file")
+	cmd.Flags().Int64Var(&extractMaxEntries, "max-entries", defaults.MaxEntries, "maximum number of entries in the archive")
+}
+
+// parseExtractLimits builds the extraction limits from the extraction limit flags.
+func parseExtractLimits() (pkg.ExtractLimits, error) {
+	maxSize, err := parseByteSize(extractMaxSize)
+	if err != nil {
+		return pkg.ExtractLimits{}, fmt.Errorf("invalid --max-size: %v", err)
+	}
+	maxFileSize, err := parseByteSize(extractMaxFileSize)
+	if err != nil {
+		return pkg.ExtractLimits{}, fmt.Errorf("invalid --max-file-size: %v", err)
+	}
+	if extractMaxEntries < 0 {
+		return pkg.ExtractLimits{}, fmt.Errorf("invalid --max-entries: must not be negative")
+	}
+
+	return pkg.ExtractLimits{
+		MaxTotalSize: maxSize,
+		MaxFileSize:  maxFileSize,
+		MaxEntries:   extractMaxEntries,
+	}, nil
+}
+
+// byteSizeUnits maps size suffixes to their multipliers, largest first.
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// parseByteSize parses a size such as "512M" or "4G" into a number of bytes.
+// The suffix may be followed by "B" or "iB" (e.g. "4GiB"); a bare number is taken as bytes.
+func parseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.multiplier
+			s = strings.TrimSuffix(s, unit.suffix)
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a valid size", value)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("%q is too large", value)
+	}
+	return n * multiplier, nil
+}
+
+// formatByteSize renders a byte count using the largest unit that divides it exactly.
+func formatByteSize(n int64) string {
+	for _, unit := range byteSizeUnits {
+		if n != 0 && n%unit.multiplier == 0 {
+			return fmt.Sprintf("%d%s", n/unit.multiplier, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// printExtractReport summarises an extraction, listing any entries that were skipped.
+func printExtractReport(report *pkg.ExtractReport) {
+	fmt.Printf("Extracted %d files, %d directories, %d symlinks and %d hardlinks.\n",
+		report.Files, report.Dirs, report.Symlinks, report.Hardlinks)
+
+	if len(report.Skipped) > 0 {
+		fmt.Printf("Skipped %d entries:\n", len(report.Skipped))
+		for _, entry := range report.Skipped {
+			fmt.Printf("  %s (%s)\n", entry.Name, entry.Reason)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"512M", 512 << 20, false},
+		{"4G", 4 << 30, false},
+		{"4GiB", 4 << 30, false},
+		{"2kb", 2 << 10, false},
+		{"8191P", 0, true},
+		{"-1", 0, true},
+		{"lots", 0, true},
+		{"8388607T", 8388607 << 40, false},
+		{"8388608T", 0, true},
+		{"9999999999T", 0, true},
+		{"9223372036854775807", 9223372036854775807, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parseByteSize(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseByteSize(%q) error = %v, want error %v", tc.value, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("parseByteSize(%q) = %d, want %d", tc.value, got, tc.want)
+			}
+		})
+	}
+}
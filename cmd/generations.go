@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Beans69584/PackageManager/pkg"
+)
+
+// replaceCurrentGeneration makes newPackage the current installation of an installed
+// package. The previous installation is demoted to a retained generation, and the
+// installation directories of generations beyond the keep limit are removed when the
+// transaction commits.
+func replaceCurrentGeneration(tx *pkg.Transaction, pm *pkg.PackageManager, cfg *pkg.Config, current, newPackage pkg.Package, keep int) error {
+	updated, pruned := pkg.NextGeneration(current, newPackage, keep)
+	if err := activatePackage(tx, pm, cfg, current, updated); err != nil {
+		return err
+	}
+
+	// Everything now points at the new payload, so pruned generations can be deleted.
+	for _, generation := range pruned {
+		if err := tx.Remove(generation.InstallPath); err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Printf("Warning: failed to remove old installation directory %s: %v\n", generation.InstallPath, err)
+			}
+		} else {
+			fmt.Printf("Removing old installation directory: %s\n", generation.InstallPath)
+		}
+	}
+	return nil
+}
+
+// activatePackage switches the symlinks and desktop entry of an installed package from
+// the current record's payload to the updated record's payload, then saves the updated
+// record in place of the current one. Every change is recorded in tx, so rolling it back
+// leaves the current installation working exactly as before.
+func activatePackage(tx *pkg.Transaction, pm *pkg.PackageManager, cfg *pkg.Config, current, updated pkg.Package) error {
+	// Atomically re-point each symlink.
+	if err := switchSymlinks(tx, cfg, current, updated); err != nil {
+		return fmt.Errorf("error switching symlinks: %v", err)
+	}
+
+	// Atomically replace the .desktop file so that it launches the new executable.
+	desktopPath := pkg.DesktopFilePath(cfg, updated.Name)
+	if !updated.NoDesktop {
+		if err := tx.Preserve(desktopPath); err != nil {
+			return err
+		}
+		if err := pkg.CreateDesktopFile(cfg, updated.Executable, updated.Name, updated.InstallPath, pkg.DesktopOptionsFor(updated)); err != nil {
+			return fmt.Errorf("error updating .desktop file: %v", err)
+		}
+	} else if !current.NoDesktop {
+		// The new payload opted out of desktop integration, so drop the old entry.
+		if err := tx.Remove(desktopPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing .desktop file: %v", err)
+		}
+	}
+
+	// Record what the package now owns, then replace the package record.
+	if err := updated.RecordOwnedFiles(cfg); err != nil {
+		return err
+	}
+	if err := tx.Preserve(pm.PackagesFile); err != nil {
+		return err
+	}
+	if err := pm.ReplacePackage(current.UUID, updated); err != nil {
+		return fmt.Errorf("error updating package record: %v", err)
+	}
+
+	return nil
+}
+
+// checkActivationConflicts refuses to switch a package to a record whose symlinks or
+// .desktop file would take over files that belong to another package or that are not
+// managed at all. Install and upgrade apply a conflict policy before activating a
+// package; rollback and doctor have none, so they must not replace anything silently.
+func checkActivationConflicts(pm *pkg.PackageManager, cfg *pkg.Config, updated pkg.Package) error {
+	var contested []string
+	for _, conflict := range pkg.FindConflicts(pm, cfg, updated) {
+		// The package keeps its name, so only its files can collide.
+		if conflict.Kind == pkg.ConflictName {
+			continue
+		}
+		contested = append(contested, conflict.String())
+	}
+	if len(contested) > 0 {
+		return fmt.Errorf("%s %s conflicts with existing files: %s", updated.Name, displayVersion(updated.Version), strings.Join(contested, "; "))
+	}
+	return nil
+}
+
+// switchSymlinks atomically points the bin directory symlinks at the updated package's
+// executables and removes links for executables it no longer ships. Each link is
+// preserved in tx before it is changed.
+func switchSymlinks(tx *pkg.Transaction, cfg *pkg.Config, previous, updated pkg.Package) error {
+	newLinks := map[string]bool{}
+
+	for _, executable := range updated.LinkedExecutables() {
+		symlinkPath := updated.SymlinkPath(cfg, executable)
+		newLinks[symlinkPath] = true
+
+		if err := tx.Preserve(symlinkPath); err != nil {
+			return err
+		}
+		if err := pkg.ReplaceSymlink(cfg.ImagePath(executable), symlinkPath); err != nil {
+			return err
+		}
+		fmt.Printf("Switched symlink: %s -> %s\n", symlinkPath, executable)
+	}
+
+	// Remove links to executables that only existed in the previous payload.
+	for _, executable := range previous.LinkedExecutables() {
+		symlinkPath := previous.SymlinkPath(cfg, executable)
+		if newLinks[symlinkPath] {
+			continue
+		}
+		target, err := os.Readlink(symlinkPath)
+		if err != nil || target != cfg.ImagePath(executable) {
+			continue
+		}
+		if err := tx.Remove(symlinkPath); err != nil {
+			return fmt.Errorf("error removing obsolete symlink %s: %v", symlinkPath, err)
+		}
+		fmt.Printf("Removed obsolete symlink: %s\n", symlinkPath)
+	}
+
+	return nil
+}
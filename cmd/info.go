@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// infoFiles lists every file in the ownership manifest instead of counting them.
+var infoFiles bool
+
+// InfoCmd represents the 'info' command for the PackageManager.
+// It shows everything recorded about an installed package, along with facts derived
+// from the filesystem: its disk usage, symlinks and .desktop file, and the archive it
+// was installed from.
+var InfoCmd = &cobra.Command{
+	Use:   "info [package_name|uuid]",
+	Short: "Show everything known about an installed package",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, pm, lock := openPackageManager(pkg.SharedLock)
+		defer lock.Release()
+
+		matches := findInstalled(pm, args[0])
+		if len(matches) == 0 {
+			fmt.Printf("Package %s not found.\n", args[0])
+			os.Exit(1)
+		}
+
+		// The keyring names the keys that signed packages; it is optional here.
+		keyring, err := pkg.LoadKeyring(cfg.KeyringPath())
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			keyring = &pkg.Keyring{}
+		}
+
+		// Older databases may hold several packages with the same name; show them all.
+		for i, p := range matches {
+			if i > 0 {
+				fmt.Println()
+			}
+			printPackageInfo(cfg, keyring, p)
+		}
+	},
+}
+
+func init() {
+	InfoCmd.Flags().BoolVar(&infoFiles, "files", false, "list every file the package owns")
+}
+
+// findInstalled returns the installed packages with a name or UUID.
+func findInstalled(pm *pkg.PackageManager, nameOrUUID string) []pkg.Package {
+	var matches []pkg.Package
+	for _, p := range pm.Packages {
+		if p.Name == nameOrUUID || p.UUID == nameOrUUID {
+			matches = append(matches, p)
+		}
+	}
+	return matches
+}
+
+// printPackageInfo prints a package record field by field, followed by derived data.
+// Fields that are empty are left out.
+func printPackageInfo(cfg *pkg.Config, keyring *pkg.Keyring, p pkg.Package) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", label, value)
+		}
+	}
+	list := func(label string, values []string) {
+		for i, value := range values {
+			if i > 0 {
+				label = ""
+			}
+			fmt.Fprintf(w, "%s\t%s\n", labelled(label), value)
+		}
+	}
+
+	field("Name", p.Name)
+	field("UUID", p.UUID)
+	field("Version", orDash(p.Version))
+	field("Description", p.Description)
+	field("Installed", displayTime(p.InstalledAt))
+	field("Install path", p.InstallPath)
+	if usage, err := pkg.DiskUsage(p.InstallPath); err != nil {
+		field("Disk usage", fmt.Sprintf("unknown (%v)", err))
+	} else {
+		field("Disk usage", formatTransferSize(usage))
+	}
+
+	// Executables and the symlinks that expose them, with the state of each link.
+	field("Executable", p.Executable)
+	if len(p.Executables) > 1 {
+		list("Executables", p.Executables)
+	}
+	var links []string
+	for _, executable := range p.LinkedExecutables() {
+		link := p.SymlinkPath(cfg, executable)
+		links = append(links, fmt.Sprintf("%s -> %s%s", link, cfg.ImagePath(executable), linkState(cfg, link, executable)))
+	}
+	list("Symlinks", links)
+	if len(p.LinkNames) > 0 {
+		var renamed []string
+		for executable, linkName := range p.LinkNames {
+			renamed = append(renamed, fmt.Sprintf("%s linked as %s", executable, linkName))
+		}
+		sort.Strings(renamed)
+		list("Link names", renamed)
+	}
+
+	// The .desktop entry and the metadata written into it.
+	if p.NoDesktop {
+		field("Desktop file", "none (installed with --no-desktop)")
+	} else {
+		desktopPath := pkg.DesktopFilePath(cfg, p.Name)
+		if _, err := os.Stat(desktopPath); err != nil {
+			desktopPath += " (missing)"
+		}
+		field("Desktop file", desktopPath)
+	}
+	field("Icon", p.Icon)
+	field("Categories", strings.Join(p.Categories, ", "))
+	if d := p.Desktop; d != nil {
+		field("Generic name", d.GenericName)
+		field("Comment", d.Comment)
+		if d.Terminal {
+			field("Terminal", "yes")
+		}
+		if d.NoDisplay {
+			field("Hidden from menus", "yes")
+		}
+		field("Keywords", strings.Join(d.Keywords, ", "))
+		field("MIME types", strings.Join(d.MimeTypes, ", "))
+		field("Startup WM class", d.StartupWMClass)
+		field("Exec arguments", d.Args)
+	}
+
+	// The archive the package came from.
+	field("Source archive", p.Source)
+	if p.ArchiveDigest != "" {
+		verified := "not verified"
+		if p.DigestVerified {
+			verified = "verified"
+		}
+		field("Archive digest", fmt.Sprintf("%s (%s)", p.ArchiveDigest, verified))
+	}
+	if p.SignedBy != "" {
+		signer := p.SignedBy
+		if key := keyring.Find(p.SignedBy); key == nil {
+			signer += " (no longer in the keyring)"
+		} else if key.Comment != "" {
+			signer += " (" + key.Comment + ")"
+		}
+		field("Signed by", signer)
+	}
+
+	// The ownership manifest.
+	if infoFiles {
+		var files []string
+		for _, owned := range p.Files {
+			files = append(files, fmt.Sprintf("%s (%s)", owned.Path, owned.Type))
+		}
+		list("Files", files)
+	} else if len(p.Files) > 0 {
+		external := len(p.ExternalFiles(cfg))
+		field("Files", fmt.Sprintf("%d owned, %d outside the install path (--files lists them)", len(p.Files), external))
+	}
+
+	// Previous installations retained for rollback.
+	var generations []string
+	for _, generation := range p.Generations {
+		usage := "unknown size"
+		if n, err := pkg.DiskUsage(generation.InstallPath); err == nil {
+			usage = formatTransferSize(n)
+		}
+		generations = append(generations, fmt.Sprintf("%s (installed %s, %s) %s", displayVersion(generation.Version), displayTime(generation.InstalledAt), usage, generation.InstallPath))
+	}
+	list("Generations", generations)
+
+	w.Flush()
+}
+
+// labelled adds the colon to a field label, leaving continuation lines blank.
+func labelled(label string) string {
+	if label == "" {
+		return ""
+	}
+	return label + ":"
+}
+
+// linkState describes a package symlink that does not point at its executable.
+func linkState(cfg *pkg.Config, link, executable string) string {
+	target, err := os.Readlink(link)
+	switch {
+	case os.IsNotExist(err):
+		return " (missing)"
+	case err != nil:
+		return " (not a symlink)"
+	case filepath.Clean(target) != cfg.ImagePath(executable):
+		return " (points to " + target + ")"
+	}
+	return ""
+}
@@ -1,196 +1,395 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Beans69584/PackageManager/pkg"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
+// Flags for the install command.
+var (
+	installVersion         string
+	installKeepGenerations int
+
+	// Flags that answer the questions install would otherwise ask on stdin.
+	installName       string
+	installExec       []string
+	installNoDesktop  bool
+	installOnConflict string
+)
+
 // InstallCmd represents the 'install' command for the PackageManager.
-// It enables users to install a package from a tar.gz archive.
+// It enables users to install a package from a tarball (gzip, bzip2, xz, zstd or uncompressed) or zip archive,
+// given as a local path, as an HTTP(S) URL that is downloaded into the cache first, or as a
+// package name, optionally with "@version", that is looked up in the repository indexes.
 var InstallCmd = &cobra.Command{
-	Use:   "install [archive.tar.gz]",
-	Short: "Install a package from a tar.gz archive",
+	Use:   "install [archive|url|name[@version]]",
+	Short: "Install a package from a tarball or zip archive, its HTTP(S) URL, or a repository",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		// Retrieve the path to the archive from the command arguments.
+		// Retrieve the path, URL or package name from the command arguments. Whether a
+		// missing local file names a package in a repository is decided once the
+		// configuration is loaded.
 		archivePath := args[0]
 
-		// Verify that the specified archive exists.
-		if _, err := os.Stat(archivePath); os.IsNotExist(err) {
-			fmt.Printf("Error: Archive %s does not exist.\n", archivePath)
+		// Resolve the extraction limits from the command-line flags.
+		limits, err := parseExtractLimits()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := validateConflictPolicy(installOnConflict); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Load the package database for the selected scope (per-user or system-wide).
+		cfg, pm, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
+		if err := cfg.EnsureDirs(); err != nil {
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Define the base directory where packages will be installed.
-		packagesDir := "/usr/local/share/packagemanager"
+		// A name that is not a local file or URL is looked up in the repository indexes,
+		// which supply the archive's location, checksum and defaults for its name and version.
+		source := archivePath
+		var indexed *pkg.IndexEntry
+		if _, err := os.Stat(source); !pkg.IsURL(source) && os.IsNotExist(err) {
+			location, entry := resolveRepositoryPackage(cfg, source)
+			source, indexed = location, &entry
+			if installName == "" {
+				installName = entry.Name
+			}
+			if installVersion == "" {
+				installVersion = entry.Version
+			}
+		}
+
+		// Download the archive into the cache if it is remote and verify its checksum and
+		// signature, then identify its format from its magic bytes before doing any work.
+		archivePath = fetchArchive(cfg, source)
+		digest, verified := verifyArchive(source, archivePath, indexed)
+		signer := checkSignature(cfg, source, archivePath)
+		format, err := pkg.DetectArchiveFormat(archivePath)
+		if err != nil {
+			fmt.Printf("Error reading archive: %v\n", err)
+			os.Exit(1)
+		}
+		if format == pkg.FormatUnknown {
+			fmt.Printf("Error: %s is not a supported archive (expected a tarball or zip).\n", archivePath)
+			os.Exit(1)
+		}
 
 		// Generate a unique identifier for this installation instance.
 		installUUID := uuid.New().String()
 
-		// Determine the default package name by stripping extensions from the archive filename.
-		defaultPackageName := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(archivePath), ".tar.gz"), ".tgz")
+		// Determine the default package name and version by stripping extensions from the archive filename.
+		defaultPackageName, archiveVersion := pkg.SplitNameVersion(packageNameFromArchive(archivePath))
 
 		// Construct the full installation path using the base directory, UUID, and default package name.
-		installPath := filepath.Join(packagesDir, fmt.Sprintf("%s-%s", installUUID, defaultPackageName))
+		installPath := filepath.Join(cfg.StoreDir, fmt.Sprintf("%s-%s", installUUID, defaultPackageName))
 
-		// Initialise the PackageManager, responsible for tracking installed packages.
-		pm, err := pkg.NewPackageManager(filepath.Join(packagesDir, "packages.json"))
-		if err != nil {
-			fmt.Printf("Error initialising PackageManager: %v\n", err)
-			os.Exit(1)
-		}
+		// Every change from here on is recorded in a transaction and undone if a step fails
+		// or the install is interrupted, so a failed install leaves nothing behind.
+		tx := beginTransaction()
+		tx.OnRollback("remove "+installPath, func() error { return os.RemoveAll(installPath) })
 
 		// Extract the contents of the archive to the designated installation path.
-		fmt.Printf("Extracting %s to %s...\n", archivePath, installPath)
-		err = pkg.ExtractTarGz(archivePath, installPath)
+		fmt.Printf("Extracting %s (%s) to %s...\n", archivePath, format, installPath)
+		report, err := pkg.ExtractArchive(archivePath, installPath, limits)
 		if err != nil {
-			fmt.Printf("Error extracting archive: %v\n", err)
-			os.Exit(1)
+			abortTransaction(tx, "Error extracting archive: %v\n", err)
 		}
+		printExtractReport(report)
 
-		// Prompt the user to input a friendly name for the package.
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Printf("Enter a friendly name for the package [%s]: ", defaultPackageName)
-		inputName, err := reader.ReadString('\n')
+		// Look for a package manifest, which can supply the name, executables and desktop metadata.
+		manifest, packageRoot, err := pkg.LoadManifest(installPath)
 		if err != nil {
-			fmt.Printf("Error reading input: %v\n", err)
-			os.Exit(1)
+			abortTransaction(tx, "Error reading package manifest: %v\n", err)
+		}
+		if manifest != nil {
+			fmt.Println("Found package manifest.")
 		}
-		inputName = strings.TrimSpace(inputName)
-		if inputName == "" {
-			inputName = defaultPackageName
+
+		// Questions are only asked on a terminal; otherwise flags and defaults must answer them.
+		prompt := newCommandPrompter(cfg)
+
+		var packageName string
+		switch {
+		case installName != "":
+			packageName = installName
+		case manifest != nil && manifest.Name != "":
+			// The manifest names the package, so there is no need to ask.
+			packageName = manifest.Name
+			fmt.Printf("Package name: %s\n", packageName)
+		default:
+			// Prompt the user to input a friendly name for the package.
+			packageName, err = prompt.askString("Enter a friendly name for the package", defaultPackageName)
+			if err != nil {
+				abortTransaction(tx, "Error: %v\n", err)
+			}
 		}
-		packageName := inputName
 
-		// Recursively search for executable files within the installation directory.
-		executables, err := findExecutablesRecursively(installPath)
+		// The key that signed the archive must be trusted for the name it is installed under.
+		signed, err := checkSigner(cfg, signer, packageName)
 		if err != nil {
-			fmt.Printf("Error searching for executables: %v\n", err)
-			os.Exit(1)
+			abortTransaction(tx, "Error: %v\n", err)
 		}
 
-		// If no executables are found, inform the user and exit.
-		if len(executables) == 0 {
-			fmt.Println("No executables found in the package.")
-			os.Exit(1)
+		// Record the version, preferring the manifest, then the --version flag, then the filename.
+		packageVersion := resolveVersion(manifest, installVersion, archiveVersion)
+		if packageVersion != "" {
+			fmt.Printf("Package version: %s\n", packageVersion)
+		}
+
+		var linkedExecutables []string
+		if len(installExec) > 0 {
+			// Executables named on the command line take precedence over the manifest.
+			for _, rel := range installExec {
+				executable, err := pkg.ResolvePackageFile(installPath, rel)
+				if err != nil {
+					abortTransaction(tx, "Error: --exec %v\n", err)
+				}
+				linkedExecutables = append(linkedExecutables, executable)
+			}
+		} else if manifest != nil && len(manifest.Executables) > 0 {
+			// The manifest declares the executables; the first one is the main executable.
+			linkedExecutables = manifest.ExecutablePaths(packageRoot)
+			fmt.Printf("Executables from manifest: %s\n", strings.Join(manifest.Executables, ", "))
+		} else {
+			selectedExecutable, err := selectExecutable(prompt, installPath)
+			if err != nil {
+				abortTransaction(tx, "Error: %v\n", err)
+			}
+			linkedExecutables = []string{selectedExecutable}
 		}
 
-		var selectedExecutable string
+		// Describe the new installation, including any metadata declared by the manifest.
+		newPackage := buildPackageRecord(installUUID, packageName, installPath, packageVersion, linkedExecutables, manifest, packageRoot)
+		newPackage.NoDesktop = installNoDesktop
+		recordArchive(&newPackage, source, digest, verified)
+		if indexed != nil && newPackage.Description == "" {
+			newPackage.Description = indexed.Description
+		}
+		if signed {
+			newPackage.SignedBy = signer.ID
+		}
+		if existing := pm.FindPackage(packageName); existing != nil {
+			// A reinstall keeps any symlink names chosen to avoid earlier conflicts.
+			newPackage.LinkNames = maps.Clone(existing.LinkNames)
+		}
 
-		// If only one executable is found, select it automatically.
-		if len(executables) == 1 {
-			selectedExecutable = executables[0]
-			fmt.Printf("Automatically selected executable: %s\n", filepath.Base(selectedExecutable))
+		// Check for collisions with other packages and unmanaged files before anything
+		// outside the store is touched, and apply the conflict policy.
+		proceed, err := resolveConflicts(prompt, pm, cfg, &newPackage, installOnConflict)
+		if err != nil {
+			abortTransaction(tx, "Error: %v\n", err)
+		}
+		if !proceed {
+			fmt.Println("Installation aborted because of conflicts.")
+			tx.Rollback()
+			os.Exit(1)
+		}
+		packageName = newPackage.Name
+
+		// If the package is already installed, the new payload becomes its current generation
+		// and the previous installation is retained for rollback instead of creating a second record.
+		if existing := pm.FindPackage(packageName); existing != nil {
+			fmt.Printf("Package '%s' is already installed (%s); installing as a new generation.\n", packageName, displayVersion(existing.Version))
+			if err := replaceCurrentGeneration(tx, pm, cfg, *existing, newPackage, installKeepGenerations); err != nil {
+				abortTransaction(tx, "Error: %v\n", err)
+			}
 		} else {
-			// If multiple executables are found, list them and prompt the user to select one.
-			fmt.Println("Multiple executables found:")
-			for i, execPath := range executables {
-				relPath, _ := filepath.Rel(installPath, execPath)
-				fmt.Printf("  %d) %s\n", i+1, relPath)
+			// Create a symbolic link in the bin directory pointing to each executable.
+			for _, executable := range linkedExecutables {
+				if err := linkExecutable(cfg, tx, newPackage, executable); err != nil {
+					abortTransaction(tx, "Error: %v\n", err)
+				}
 			}
 
-			// Improved executable selection process.
-			for {
-				fmt.Printf("Select an executable to symlink (1-%d): ", len(executables))
-				input, err := reader.ReadString('\n')
-				if err != nil {
-					fmt.Printf("Error reading input: %v\n", err)
-					continue
+			// Create a .desktop file to integrate the application with desktop environments.
+			if !newPackage.NoDesktop {
+				if err := tx.Preserve(pkg.DesktopFilePath(cfg, packageName)); err != nil {
+					abortTransaction(tx, "Error: %v\n", err)
 				}
-				input = strings.TrimSpace(input)
-				choice, err := strconv.Atoi(input)
-				if err != nil || choice < 1 || choice > len(executables) {
-					fmt.Println("Invalid selection. Please enter a valid number.")
-					continue
+				err = pkg.CreateDesktopFile(cfg, newPackage.Executable, packageName, installPath, pkg.DesktopOptionsFor(newPackage))
+				if err != nil {
+					abortTransaction(tx, "Error creating .desktop file: %v\n", err)
 				}
-				selectedExecutable = executables[choice-1]
-				fmt.Printf("Selected executable: %s\n", filepath.Base(selectedExecutable))
-				break
 			}
-		}
-
-		// Create a symbolic link in /usr/local/bin pointing to the selected executable.
-		symlinkName := filepath.Base(selectedExecutable)
-		symlinkPath := filepath.Join("/usr/local/bin", symlinkName)
 
-		// Check if the symlink path already exists and handle accordingly.
-		if _, err := os.Lstat(symlinkPath); err == nil {
-			fmt.Printf("Symlink %s already exists. Overwrite? (y/n): ", symlinkPath)
-			overwriteInput, err := reader.ReadString('\n')
-			if err != nil {
-				fmt.Printf("Error reading input: %v\n", err)
-				os.Exit(1)
+			// Record every file the package owns, then add it to the PackageManager's tracking system.
+			if err := newPackage.RecordOwnedFiles(cfg); err != nil {
+				abortTransaction(tx, "Error: %v\n", err)
 			}
-			overwriteInput = strings.TrimSpace(strings.ToLower(overwriteInput))
-			if overwriteInput != "y" && overwriteInput != "yes" {
-				fmt.Println("Installation aborted by user.")
-				os.Exit(0)
+			if err := tx.Preserve(pm.PackagesFile); err != nil {
+				abortTransaction(tx, "Error: %v\n", err)
 			}
-
-			// Remove the existing symlink to make way for the new one.
-			err = os.Remove(symlinkPath)
+			err = pm.AddPackage(newPackage)
 			if err != nil {
-				fmt.Printf("Error removing existing symlink: %v\n", err)
-				os.Exit(1)
+				abortTransaction(tx, "Error adding package to PackageManager: %v\n", err)
 			}
 		}
 
-		// Create the new symlink.
-		err = os.Symlink(selectedExecutable, symlinkPath)
-		if err != nil {
-			fmt.Printf("Error creating symlink: %v\n", err)
-			os.Exit(1)
+		// Every step succeeded, so the install can no longer be rolled back.
+		tx.Commit()
+
+		fmt.Printf("Package '%s' installed successfully.\n", packageName)
+		if cfg.Scope == pkg.ScopeUser {
+			warnIfNotOnPath(cfg.BinDir)
 		}
 
-		fmt.Printf("Created symlink: %s -> %s\n", symlinkPath, selectedExecutable)
+		// Run the refresh hooks, e.g. so that launchers pick up desktop entry changes.
+		runRefreshHooks(cfg)
+	},
+}
+
+func init() {
+	InstallCmd.Flags().StringVar(&installName, "name", "", "friendly name for the package (skips the name prompt)")
+	InstallCmd.Flags().StringSliceVar(&installExec, "exec", nil, "executable to link, relative to the package (repeatable; the first is the main executable)")
+	InstallCmd.Flags().BoolVar(&installNoDesktop, "no-desktop", false, "do not create a .desktop file")
+	addPromptFlags(InstallCmd)
+	InstallCmd.Flags().StringVar(&installVersion, "version", "", "version of the package (defaults to the manifest or archive filename)")
+	InstallCmd.Flags().IntVar(&installKeepGenerations, "keep-generations", pkg.DefaultRetainedGenerations, "number of previous installations to retain for rollback when reinstalling")
+	addChecksumFlags(InstallCmd)
+	addSignatureFlags(InstallCmd)
+	addConflictFlag(InstallCmd, &installOnConflict)
+	addDownloadFlags(InstallCmd)
+	addExtractLimitFlags(InstallCmd)
+}
+
+// buildPackageRecord describes a freshly extracted payload as a package record, copying
+// the description, icon, categories and desktop metadata from its manifest when present.
+func buildPackageRecord(installUUID, name, installPath, version string, executables []string, manifest *pkg.Manifest, packageRoot string) pkg.Package {
+	p := pkg.Package{
+		UUID:        installUUID,
+		Name:        name,
+		InstallPath: installPath,
+		Executable:  executables[0],
+		Version:     version,
+		InstalledAt: time.Now().UTC(),
+	}
+	if len(executables) > 1 {
+		p.Executables = executables
+	}
+	if manifest != nil {
+		p.Description = manifest.Description
+		p.Icon = manifest.IconPath(packageRoot)
+		p.Categories = manifest.Categories
+		desktop := manifest.Desktop
+		p.Desktop = &desktop
+	}
+	return p
+}
+
+// resolveVersion picks a package's version from, in order of preference, its manifest,
+// the version given on the command line and the version parsed from the archive filename.
+func resolveVersion(manifest *pkg.Manifest, flagVersion, archiveVersion string) string {
+	if manifest != nil && manifest.Version != "" {
+		return manifest.Version
+	}
+	if flagVersion != "" {
+		return flagVersion
+	}
+	return archiveVersion
+}
 
-		// Create a .desktop file to integrate the application with desktop environments.
-		err = pkg.CreateDesktopFile(selectedExecutable, packageName, installPath)
+// selectExecutable finds the executables in an extracted package and picks the one to link,
+// asking the user to choose when there is more than one. In non-interactive mode more than
+// one candidate is an error, since there is no safe way to choose.
+func selectExecutable(prompt *prompter, installPath string) (string, error) {
+	// Recursively search for executable files within the installation directory.
+	executables, err := findExecutablesRecursively(installPath)
+	if err != nil {
+		return "", fmt.Errorf("error searching for executables: %v", err)
+	}
+
+	// If no executables are found, there is nothing to link.
+	if len(executables) == 0 {
+		return "", fmt.Errorf("no executables found in the package")
+	}
+
+	// If only one executable is found, select it automatically.
+	if len(executables) == 1 {
+		fmt.Printf("Automatically selected executable: %s\n", filepath.Base(executables[0]))
+		return executables[0], nil
+	}
+
+	// If multiple executables are found, list them and prompt the user to select one.
+	fmt.Println("Multiple executables found:")
+	for i, execPath := range executables {
+		relPath, _ := filepath.Rel(installPath, execPath)
+		fmt.Printf("  %d) %s\n", i+1, relPath)
+	}
+
+	if !prompt.interactive {
+		return "", fmt.Errorf("multiple executables found (non-interactive mode; use --exec to choose one)")
+	}
+
+	for {
+		fmt.Printf("Select an executable to symlink (1-%d): ", len(executables))
+		input, err := prompt.readLine()
 		if err != nil {
-			fmt.Printf("Error creating .desktop file: %v\n", err)
-			// Optionally, remove the symlink if .desktop creation fails.
-			os.Remove(symlinkPath)
-			os.Exit(1)
+			return "", err
 		}
-
-		// Add the package to the PackageManager's tracking system.
-		newPackage := pkg.Package{
-			UUID:        installUUID,
-			Name:        packageName,
-			InstallPath: installPath,
-			Executable:  selectedExecutable,
+		choice, err := strconv.Atoi(input)
+		if err != nil || choice < 1 || choice > len(executables) {
+			fmt.Println("Invalid selection. Please enter a valid number.")
+			continue
 		}
+		fmt.Printf("Selected executable: %s\n", filepath.Base(executables[choice-1]))
+		return executables[choice-1], nil
+	}
+}
 
-		err = pm.AddPackage(newPackage)
-		if err != nil {
-			fmt.Printf("Error adding package to PackageManager: %v\n", err)
-			// Optionally, remove symlink and .desktop file if tracking fails.
-			os.Remove(symlinkPath)
-			pkg.RemoveDesktopFile(packageName)
-			os.Exit(1)
+// linkExecutable creates a symlink in the bin directory pointing to one of the package's
+// executables. Conflicts have already been resolved, so an existing file at the symlink
+// path is replaced. Both the replacement and the new link are recorded in tx.
+func linkExecutable(cfg *pkg.Config, tx *pkg.Transaction, p pkg.Package, executable string) error {
+	symlinkPath := p.SymlinkPath(cfg, executable)
+
+	// Move an existing file out of the way; it is put back if the install fails.
+	if _, err := os.Lstat(symlinkPath); err == nil {
+		if err := tx.Remove(symlinkPath); err != nil {
+			return fmt.Errorf("error removing existing symlink: %v", err)
 		}
+	}
 
-		fmt.Printf("Package '%s' installed successfully.\n", packageName)
+	// Create the new symlink. Its target is the path inside an alternate root, if any,
+	// so that the link resolves once the root is booted.
+	if err := tx.Symlink(cfg.ImagePath(executable), symlinkPath); err != nil {
+		return fmt.Errorf("error creating symlink: %v", err)
+	}
 
-		// Attempt to terminate the AGS bus to refresh desktop entries.
-		killCmd := exec.Command("ags", "quit")
-		err = killCmd.Run()
-		if err != nil {
-			fmt.Printf("Warning: Failed to kill AGS bus: %v\n", err)
-		} else {
-			fmt.Println("AGS bus killed successfully.")
+	fmt.Printf("Created symlink: %s -> %s\n", symlinkPath, executable)
+	return nil
+}
+
+// archiveExtensions lists the archive suffixes stripped when deriving a package name.
+// Longer, compound suffixes must come before their shorter counterparts.
+var archiveExtensions = append(pkg.TarballExtensions(), ".zip")
+
+// packageNameFromArchive derives a default package name from an archive's filename
+// by removing any known archive extension.
+func packageNameFromArchive(archivePath string) string {
+	name := filepath.Base(archivePath)
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
 		}
-	},
+	}
+	return name
 }
 
 // findExecutablesRecursively searches for executable files within the given directory and its subdirectories.
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the key add command.
+var (
+	keyPackages []string
+	keyComment  string
+)
+
+// KeyCmd represents the 'key' command for the PackageManager.
+// It manages the keyring of publisher keys trusted to sign archives. Each scope has its
+// own keyring, kept in its store directory.
+var KeyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage the publisher keys trusted to sign archives",
+}
+
+// KeyAddCmd adds a minisign public key to the keyring, optionally limited to some packages.
+var KeyAddCmd = &cobra.Command{
+	Use:   "add [public_key_file|public_key]",
+	Short: "Trust a minisign public key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Accept either a minisign .pub file or the key itself, as published by most projects.
+		text, err := os.ReadFile(args[0])
+		if err != nil {
+			text = []byte(args[0])
+		}
+		key, err := pkg.ParsePublicKey(text)
+		if err != nil {
+			fmt.Printf("Error: %s is neither a readable public key file nor a minisign public key: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		for _, pattern := range keyPackages {
+			if _, err := path.Match(pattern, ""); err != nil {
+				fmt.Printf("Error: invalid package pattern %q: %v\n", pattern, err)
+				os.Exit(1)
+			}
+		}
+		key.Comment = keyComment
+		key.Packages = keyPackages
+		key.Added = time.Now().UTC()
+
+		cfg, _, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
+		keyring, err := pkg.LoadKeyring(cfg.KeyringPath())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		replaced := keyring.Add(key)
+		if err := keyring.Save(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if replaced {
+			fmt.Printf("Updated key %s; it is trusted to sign %s.\n", key.ID, key.Scope())
+		} else {
+			fmt.Printf("Added key %s; it is trusted to sign %s.\n", key.ID, key.Scope())
+		}
+	},
+}
+
+// KeyListCmd lists the trusted keys and the packages each may sign.
+var KeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the trusted keys",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _, lock := openPackageManager(pkg.SharedLock)
+		defer lock.Release()
+		keyring, err := pkg.LoadKeyring(cfg.KeyringPath())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(keyring.Keys) == 0 {
+			fmt.Println("No keys are trusted.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tPACKAGES\tADDED\tCOMMENT")
+		fmt.Fprintln(w, "--\t--------\t-----\t-------")
+		for _, key := range keyring.Keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.ID, key.Scope(), key.Added.Local().Format("2006-01-02"), orDash(key.Comment))
+		}
+		w.Flush()
+	},
+}
+
+// KeyRemoveCmd removes a key from the keyring. Packages it signed stay installed.
+var KeyRemoveCmd = &cobra.Command{
+	Use:   "remove [key_id]",
+	Short: "Stop trusting a key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
+		keyring, err := pkg.LoadKeyring(cfg.KeyringPath())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !keyring.Remove(args[0]) {
+			fmt.Printf("Error: key %s is not in the keyring.\n", args[0])
+			os.Exit(1)
+		}
+		if err := keyring.Save(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed key %s.\n", args[0])
+	},
+}
+
+func init() {
+	KeyAddCmd.Flags().StringSliceVar(&keyPackages, "package", nil, "package name or pattern (e.g. 'mytool-*') the key may sign; repeatable (default: any package)")
+	KeyAddCmd.Flags().StringVar(&keyComment, "comment", "", "a note on whose key this is")
+
+	KeyCmd.AddCommand(KeyAddCmd)
+	KeyCmd.AddCommand(KeyListCmd)
+	KeyCmd.AddCommand(KeyRemoveCmd)
+}
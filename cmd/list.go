@@ -3,29 +3,24 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"text/tabwriter"
 
 	"github.com/Beans69584/PackageManager/pkg"
 	"github.com/spf13/cobra"
 )
 
+// listDigests adds the archive each package was installed from to the listing.
+var listDigests bool
+
 // ListCmd represents the 'list' command for the PackageManager.
 // It allows users to view all currently installed packages.
 var ListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all installed packages",
 	Run: func(cmd *cobra.Command, args []string) {
-		// Define the base directory where packages are installed.
-		packagesDir := "/usr/local/share/packagemanager"
-
-		// Initialise the PackageManager, which manages the tracking of installed packages.
-		pm, err := pkg.NewPackageManager(filepath.Join(packagesDir, "packages.json"))
-		if err != nil {
-			// If there is an error initializing the PackageManager, inform the user and exit.
-			fmt.Printf("Error initializing PackageManager: %v\n", err)
-			os.Exit(1)
-		}
+		// Load the package database for the selected scope (per-user or system-wide).
+		_, pm, lock := openPackageManager(pkg.SharedLock)
+		defer lock.Release()
 
 		// Check if there are any packages installed.
 		if len(pm.Packages) == 0 {
@@ -38,16 +33,53 @@ var ListCmd = &cobra.Command{
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
 		// Print the header row with column titles.
-		fmt.Fprintln(w, "NAME\tINSTALL PATH\tEXECUTABLE")
-		fmt.Fprintln(w, "----\t------------\t-----------")
+		if listDigests {
+			fmt.Fprintln(w, "NAME\tVERSION\tARCHIVE DIGEST\tVERIFIED\tSOURCE")
+			fmt.Fprintln(w, "----\t-------\t--------------\t--------\t------")
+		} else {
+			fmt.Fprintln(w, "NAME\tVERSION\tINSTALL PATH\tEXECUTABLE")
+			fmt.Fprintln(w, "----\t-------\t------------\t-----------")
+		}
 
 		// Iterate over each installed package and print its details.
 		for _, p := range pm.Packages {
-			// Format each package's name, installation path, and executable path into the tabbed format.
-			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.InstallPath, p.Executable)
+			// Format each package's name, version, installation path, and executable path into the tabbed format.
+			version := p.Version
+			if version == "" {
+				version = "-"
+			}
+			if listDigests {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Name, version, orDash(p.ArchiveDigest), verifiedLabel(p), orDash(p.Source))
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Name, version, p.InstallPath, p.Executable)
 		}
 
 		// Flush the writer to ensure all output is written to the terminal.
 		w.Flush()
 	},
 }
+
+func init() {
+	ListCmd.Flags().BoolVar(&listDigests, "digests", false, "show the archive digest and source of each package instead of its paths")
+}
+
+// verifiedLabel describes whether a package's archive digest was checked against an
+// expected checksum. Packages installed before digests were recorded have none.
+func verifiedLabel(p pkg.Package) string {
+	switch {
+	case p.ArchiveDigest == "":
+		return "-"
+	case p.DigestVerified:
+		return "yes"
+	}
+	return "no"
+}
+
+// orDash renders an empty field as "-".
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Flags that answer or suppress the questions a command would otherwise ask on stdin,
+// shared by every command that prompts.
+var (
+	promptYes            bool
+	promptNonInteractive bool
+)
+
+// addPromptFlags registers --yes, its alias --force, and --non-interactive on a command
+// that may prompt.
+func addPromptFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&promptYes, "yes", "y", false, "answer yes to confirmations; replaces conflicting files unless --on-conflict says otherwise")
+	cmd.Flags().BoolVar(&promptYes, "force", false, "alias for --yes")
+	cmd.Flags().BoolVar(&promptNonInteractive, "non-interactive", false, "never prompt; fail if a choice is ambiguous (implied when stdin is not a terminal)")
+}
+
+// newCommandPrompter creates the prompter for a command, combining the prompt flags
+// with the non_interactive and assume_yes settings.
+func newCommandPrompter(cfg *pkg.Config) *prompter {
+	return newPrompter(promptNonInteractive || cfg.NonInteractive, promptYes || cfg.AssumeYes)
+}
+
+// prompter asks the user questions on the terminal. In non-interactive mode it never
+// reads from stdin: questions with a safe default use it, and anything that genuinely
+// needs an answer fails with an error naming the flag that would supply it.
+type prompter struct {
+	reader      *bufio.Reader
+	interactive bool // Whether the user may be prompted at all.
+	assumeYes   bool // Whether confirmations are answered "yes" automatically.
+}
+
+// newPrompter creates a prompter reading from stdin. Prompting is disabled when
+// nonInteractive is set or when stdin is not a terminal, as under provisioning tools.
+func newPrompter(nonInteractive, assumeYes bool) *prompter {
+	return &prompter{
+		reader:      bufio.NewReader(os.Stdin),
+		interactive: !nonInteractive && stdinIsTerminal(),
+		assumeYes:   assumeYes,
+	}
+}
+
+// stdinIsTerminal reports whether stdin is attached to a terminal.
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// readLine reads one trimmed line of input.
+func (p *prompter) readLine() (string, error) {
+	input, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %v", err)
+	}
+	return strings.TrimSpace(input), nil
+}
+
+// askString asks for a value, returning defaultValue if the user enters nothing or
+// if prompting is disabled.
+func (p *prompter) askString(question, defaultValue string) (string, error) {
+	if !p.interactive {
+		return defaultValue, nil
+	}
+
+	fmt.Printf("%s [%s]: ", question, defaultValue)
+	input, err := p.readLine()
+	if err != nil {
+		return "", err
+	}
+	if input == "" {
+		return defaultValue, nil
+	}
+	return input, nil
+}
+
+// confirm asks a yes/no question. It returns true without asking when confirmations are
+// assumed, and fails in non-interactive mode, naming hint as the way to proceed.
+func (p *prompter) confirm(question, hint string) (bool, error) {
+	if p.assumeYes {
+		return true, nil
+	}
+	if !p.interactive {
+		return false, fmt.Errorf("%s (non-interactive mode; %s)", question, hint)
+	}
+
+	fmt.Printf("%s (y/n): ", question)
+	input, err := p.readLine()
+	if err != nil {
+		return false, err
+	}
+	input = strings.ToLower(input)
+	return input == "y" || input == "yes", nil
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// RepoCmd represents the 'repo' command for the PackageManager.
+// It manages the repositories that packages can be installed from by name. Each scope
+// has its own list of repositories, kept in its store directory.
+var RepoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "Manage the repositories packages are installed from by name",
+}
+
+// RepoAddCmd adds a directory or HTTP(S) URL as a repository.
+var RepoAddCmd = &cobra.Command{
+	Use:   "add [name] [directory|url]",
+	Short: "Add a package repository",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, location := args[0], args[1]
+		if name == "" || name != filepath.Base(name) || name[0] == '.' {
+			fmt.Printf("Error: invalid repository name %q.\n", name)
+			os.Exit(1)
+		}
+
+		// A local repository is recorded by its absolute path, so it works from any directory.
+		if !pkg.IsURL(location) {
+			absolute, err := filepath.Abs(location)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if info, err := os.Stat(absolute); err != nil || !info.IsDir() {
+				fmt.Printf("Error: %s is not a directory or an HTTP(S) URL.\n", location)
+				os.Exit(1)
+			}
+			location = absolute
+		}
+
+		cfg, _, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
+		repos := loadRepositories(cfg)
+		if repos.Find(name) != nil {
+			fmt.Printf("Error: a repository named %s already exists.\n", name)
+			os.Exit(1)
+		}
+
+		repos.Repositories = append(repos.Repositories, pkg.Repository{Name: name, URL: location, Added: time.Now().UTC()})
+		if err := repos.Save(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added repository %s (%s). Run 'update' to fetch its index.\n", name, location)
+	},
+}
+
+// RepoRemoveCmd removes a repository and its cached index. Packages installed from it stay installed.
+var RepoRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a package repository",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
+		repos := loadRepositories(cfg)
+
+		if !repos.Remove(args[0]) {
+			fmt.Printf("Error: there is no repository named %s.\n", args[0])
+			os.Exit(1)
+		}
+		if err := repos.Save(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.Remove(pkg.IndexCachePath(cfg, args[0])); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: error removing the cached index: %v\n", err)
+		}
+		fmt.Printf("Removed repository %s.\n", args[0])
+	},
+}
+
+// RepoListCmd lists the repositories with the number of packages in their cached index.
+var RepoListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the package repositories",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _, lock := openPackageManager(pkg.SharedLock)
+		defer lock.Release()
+		repos := loadRepositories(cfg)
+
+		if len(repos.Repositories) == 0 {
+			fmt.Println("No repositories configured.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tURL\tPACKAGES\tUPDATED")
+		fmt.Fprintln(w, "----\t---\t--------\t-------")
+		for _, repo := range repos.Repositories {
+			packages, updated := "-", "never"
+			if index, err := pkg.LoadIndex(cfg, repo); err == nil {
+				packages = strconv.Itoa(len(index.Packages))
+			}
+			if info, err := os.Stat(pkg.IndexCachePath(cfg, repo.Name)); err == nil {
+				updated = info.ModTime().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", repo.Name, repo.URL, packages, updated)
+		}
+		w.Flush()
+	},
+}
+
+// UpdateCmd represents the 'update' command for the PackageManager.
+// It fetches the index of every configured repository, so that packages can be installed
+// by name. A repository that cannot be reached keeps its previously fetched index.
+var UpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Fetch the indexes of the package repositories",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
+		repos := loadRepositories(cfg)
+
+		if len(repos.Repositories) == 0 {
+			fmt.Println("No repositories configured. Add one with 'repo add'.")
+			return
+		}
+
+		failed := false
+		for _, repo := range repos.Repositories {
+			index, err := pkg.UpdateIndex(cfg, repo, downloadTimeout)
+			if err != nil {
+				fmt.Printf("Error updating %s: %v\n", repo.Name, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("Updated %s: %d packages.\n", repo.Name, len(index.Packages))
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RepoCmd.AddCommand(RepoAddCmd)
+	RepoCmd.AddCommand(RepoRemoveCmd)
+	RepoCmd.AddCommand(RepoListCmd)
+	addDownloadFlags(UpdateCmd)
+}
+
+// loadRepositories reads the configured repositories, exiting with an error message if
+// the list cannot be read.
+func loadRepositories(cfg *pkg.Config) *pkg.RepositoryList {
+	repos, err := pkg.LoadRepositories(cfg.RepositoriesPath())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return repos
+}
+
+// resolveRepositoryPackage looks up a package reference such as "mytool" or
+// "mytool@1.2.0" in the repository indexes, exiting with an error message if it cannot be
+// found. It returns the archive's location and the index entry describing it.
+func resolveRepositoryPackage(cfg *pkg.Config, ref string) (string, pkg.IndexEntry) {
+	name, version := pkg.ParsePackageReference(ref)
+	entry, repo, err := pkg.ResolvePackage(cfg, loadRepositories(cfg), name, version)
+	if err != nil {
+		fmt.Printf("Error: %s is not an archive or URL, and %v.\n", ref, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Found %s %s in repository %s.\n", entry.Name, displayVersion(entry.Version), repo.Name)
+	return repo.Resolve(entry.Archive), entry
+}
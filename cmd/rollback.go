@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the rollback command.
+var (
+	rollbackVersion string
+)
+
+// RollbackCmd represents the 'rollback' command for the PackageManager.
+// It switches an installed package back to one of its retained generations by
+// re-pointing the bin symlinks and the .desktop entry at the older installation
+// directory. The installation being replaced is itself retained, so a rollback
+// can be undone with another rollback using --to.
+var RollbackCmd = &cobra.Command{
+	Use:   "rollback [package_name]",
+	Short: "Roll a package back to a previously installed version",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Retrieve the package name from the command arguments.
+		packageName := args[0]
+
+		// Load the package database for the selected scope (per-user or system-wide).
+		cfg, pm, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
+
+		// Find the package to roll back.
+		targetPackage := pm.FindPackage(packageName)
+		if targetPackage == nil {
+			fmt.Printf("Package %s not found.\n", packageName)
+			os.Exit(1)
+		}
+		current := *targetPackage
+
+		// Choose the generation to restore.
+		updated, err := pkg.RollbackGeneration(current, rollbackVersion)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			printGenerations(current)
+			os.Exit(1)
+		}
+
+		// Make sure the retained installation is still on disk before switching to it.
+		if _, err := os.Stat(updated.InstallPath); err != nil {
+			fmt.Printf("Error: installation directory %s is missing: %v\n", updated.InstallPath, err)
+			os.Exit(1)
+		}
+
+		// The retained generation may link executables that another package has claimed since.
+		if err := checkActivationConflicts(pm, cfg, updated); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Rolling back %s from %s to %s\n", packageName, displayVersion(current.Version), displayVersion(updated.Version))
+		tx := beginTransaction()
+		if err := activatePackage(tx, pm, cfg, current, updated); err != nil {
+			abortTransaction(tx, "Error: %v\n", err)
+		}
+		tx.Commit()
+
+		fmt.Printf("Package '%s' rolled back successfully.\n", packageName)
+
+		// Run the refresh hooks, e.g. so that launchers pick up desktop entry changes.
+		runRefreshHooks(cfg)
+	},
+}
+
+func init() {
+	RollbackCmd.Flags().StringVar(&rollbackVersion, "to", "", "version to roll back to (defaults to the generation installed before the current one)")
+}
+
+// printGenerations lists the retained generations of a package.
+func printGenerations(p pkg.Package) {
+	if len(p.Generations) == 0 {
+		fmt.Printf("No previous generations of %s are retained.\n", p.Name)
+		return
+	}
+
+	fmt.Printf("Retained generations of %s:\n", p.Name)
+	for _, generation := range p.Generations {
+		fmt.Printf("  %s (installed %s) %s\n", displayVersion(generation.Version), displayTime(generation.InstalledAt), generation.InstallPath)
+	}
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// SearchCmd represents the 'search' command for the PackageManager.
+// It looks for a term in the names, descriptions, executables and README titles of the
+// installed packages and of the archives in the configured source directories, and in
+// the names and descriptions listed by the repository indexes.
+var SearchCmd = &cobra.Command{
+	Use:   "search [term]",
+	Short: "Search installed packages, source directories and repositories",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, pm, lock := openPackageManager(pkg.SharedLock)
+		defer lock.Release()
+		term := strings.ToLower(args[0])
+
+		var results []searchResult
+
+		// Installed packages.
+		for _, p := range pm.Packages {
+			fields := []searchField{{"name", p.Name}, {"description", p.Description}}
+			for _, executable := range p.LinkedExecutables() {
+				fields = append(fields, searchField{"executable", filepath.Base(executable)})
+			}
+			fields = append(fields, searchField{"README", pkg.ReadmeTitle(p.InstallPath)})
+			results = appendMatch(results, term, p.Name, p.Version, "installed", fields)
+		}
+
+		// Archives in the source directories, read without extracting them.
+		for _, dir := range cfg.SourceDirs {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				fmt.Printf("Warning: error reading source directory: %v\n", err)
+				continue
+			}
+			for _, entry := range entries {
+				archivePath := filepath.Join(dir, entry.Name())
+				if !entry.Type().IsRegular() {
+					continue
+				}
+				if format, err := pkg.DetectArchiveFormat(archivePath); err != nil || format == pkg.FormatUnknown {
+					continue
+				}
+
+				name, version := pkg.SplitNameVersion(packageNameFromArchive(archivePath))
+				fields := []searchField{{"name", name}}
+				contents, err := pkg.InspectArchive(archivePath)
+				if err != nil {
+					fmt.Printf("Warning: %s: %v\n", archivePath, err)
+				} else {
+					for _, executable := range contents.Executables {
+						fields = append(fields, searchField{"executable", executable})
+					}
+					fields = append(fields, searchField{"README", contents.ReadmeTitle})
+				}
+				results = appendMatch(results, term, name, version, archivePath, fields)
+			}
+		}
+
+		// Packages published by repositories, as of the last update.
+		repos := loadRepositories(cfg)
+		for _, repo := range repos.Repositories {
+			index, err := pkg.LoadIndex(cfg, repo)
+			if err != nil {
+				continue
+			}
+			for _, entry := range index.Packages {
+				fields := []searchField{{"name", entry.Name}, {"description", entry.Description}}
+				results = appendMatch(results, term, entry.Name, entry.Version, "repository "+repo.Name, fields)
+			}
+		}
+
+		if len(results) == 0 {
+			fmt.Printf("No packages match %q.\n", args[0])
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tSOURCE\tMATCHED")
+		fmt.Fprintln(w, "----\t-------\t------\t-------")
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.name, orDash(result.version), result.source, strings.Join(result.matches, ", "))
+		}
+		w.Flush()
+	},
+}
+
+// searchField is a labelled value that a search term is matched against.
+type searchField struct {
+	label string
+	value string
+}
+
+// searchResult is a package that matched a search, with a description of each match.
+type searchResult struct {
+	name    string
+	version string
+	source  string
+	matches []string
+}
+
+// appendMatch adds a package to the results if the term occurs in any of its fields,
+// ignoring case. Matches on anything but the name show the matching value.
+func appendMatch(results []searchResult, term, name, version, source string, fields []searchField) []searchResult {
+	var matches []string
+	for _, field := range fields {
+		if field.value == "" || !strings.Contains(strings.ToLower(field.value), term) {
+			continue
+		}
+		if field.label == "name" {
+			matches = append(matches, field.label)
+		} else {
+			matches = append(matches, fmt.Sprintf("%s %q", field.label, field.value))
+		}
+	}
+	if len(matches) == 0 {
+		return results
+	}
+	return append(results, searchResult{name: name, version: version, source: source, matches: matches})
+}
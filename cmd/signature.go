@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// archiveSignature is the location of the archive's signature given with --signature.
+var archiveSignature string
+
+// addSignatureFlags registers the flags for commands that verify an archive's signature.
+func addSignatureFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&archiveSignature, "signature", "", "path or URL of the archive's minisign signature (defaults to the archive with "+pkg.SignatureExtension+" appended)")
+}
+
+// checkSignature verifies the archive's detached signature against the keyring before
+// anything is extracted from it. A signature that does not match is always fatal. An
+// unsigned archive, one signed by a key that is not in the keyring, or one whose server
+// will not say whether it is signed, is only refused when signature_policy is "required"
+// or, for the last, when --signature names the signature. It returns the key that signed the archive, or
+// nil if the signature could not be checked.
+func checkSignature(cfg *pkg.Config, source, archivePath string) *pkg.TrustedKey {
+	required := cfg.SignaturePolicy == pkg.SignaturesRequired
+
+	keyring, err := pkg.LoadKeyring(cfg.KeyringPath())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	signature, location, err := pkg.FindSignature(source, archiveSignature, downloadTimeout)
+	var unavailable *pkg.UnavailableError
+	if errors.As(err, &unavailable) && !required && archiveSignature == "" {
+		fmt.Printf("Warning: %v; the signature has not been checked.\n", err)
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if signature == nil {
+		if required {
+			fmt.Printf("Error: the archive is not signed (no signature at %s) and signatures are required.\n", location)
+			os.Exit(1)
+		}
+		return nil
+	}
+
+	signer, err := pkg.VerifySignature(archivePath, signature, keyring)
+	var unknown *pkg.UnknownKeyError
+	if errors.As(err, &unknown) && !required {
+		fmt.Printf("Warning: %v; the signature has not been checked. Use 'key add' to trust it.\n", err)
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Verified signature by key %s.\n", signer.ID)
+	return signer
+}
+
+// checkSigner checks that the key that signed the archive may sign the package being
+// installed. When signatures are required, a key that is not trusted for the package is
+// an error; otherwise a warning is printed. It reports whether the package should be
+// recorded as signed by the key.
+func checkSigner(cfg *pkg.Config, signer *pkg.TrustedKey, packageName string) (bool, error) {
+	if signer == nil {
+		return false, nil
+	}
+	if signer.TrustedFor(packageName) {
+		return true, nil
+	}
+
+	err := fmt.Errorf("key %s is not trusted to sign %s (it may sign %s)", signer.ID, packageName, signer.Scope())
+	if cfg.SignaturePolicy == pkg.SignaturesRequired {
+		return false, err
+	}
+	fmt.Printf("Warning: %v.\n", err)
+	return false, nil
+}
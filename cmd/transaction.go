@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Beans69584/PackageManager/pkg"
+)
+
+// beginTransaction starts a transaction for a command that modifies packages. It is
+// rolled back automatically if the command is interrupted with SIGINT or SIGTERM.
+func beginTransaction() *pkg.Transaction {
+	tx := pkg.NewTransaction()
+	tx.HandleSignals()
+	return tx
+}
+
+// abortTransaction prints an error message, undoes every change recorded in tx and
+// exits with a non-zero status.
+func abortTransaction(tx *pkg.Transaction, message string, a ...interface{}) {
+	fmt.Printf(message, a...)
+	if err := tx.Rollback(); err != nil {
+		fmt.Printf("Error: %v; the package may need to be repaired by hand.\n", err)
+	} else {
+		fmt.Println("All changes were undone.")
+	}
+	os.Exit(1)
+}
@@ -3,8 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 
 	"github.com/Beans69584/PackageManager/pkg"
 	"github.com/spf13/cobra"
@@ -13,92 +11,90 @@ import (
 // UninstallCmd represents the 'uninstall' command for the PackageManager.
 // It enables users to remove an installed package by specifying its name.
 var UninstallCmd = &cobra.Command{
-	Use:   "uninstall [package_name]",
-	Short: "Uninstall a package by name",
+	Use:   "uninstall [package_name|uuid]",
+	Short: "Uninstall a package by name or UUID",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		// Retrieve the package name from the command arguments.
 		packageName := args[0]
 
-		// Define the base directory where packages are installed.
-		packagesDir := "/usr/local/share/packagemanager"
+		// Load the package database for the selected scope (per-user or system-wide).
+		cfg, pm, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
 
-		// Initialise the PackageManager, which manages the tracking of installed packages.
-		pm, err := pkg.NewPackageManager(filepath.Join(packagesDir, "packages.json"))
-		if err != nil {
-			// If there is an error initializing the PackageManager, inform the user and exit.
-			fmt.Printf("Error initialising PackageManager: %v\n", err)
-			os.Exit(1)
-		}
-
-		// Search for the target package by name within the list of installed packages.
-		var targetPackage *pkg.Package
-		for _, p := range pm.Packages {
-			if p.Name == packageName {
-				targetPackage = &p
-				break
-			}
-		}
+		// Search for the target package by name or UUID within the list of installed packages.
+		matches := findInstalled(pm, packageName)
 
 		// If the package is not found, inform the user and exit.
-		if targetPackage == nil {
+		if len(matches) == 0 {
 			fmt.Printf("Package %s not found.\n", packageName)
 			os.Exit(1)
 		}
 
-		// Construct the path to the symbolic link in /usr/local/bin.
-		symlinkPath := filepath.Join("/usr/local/bin", filepath.Base(targetPackage.Executable))
-
-		// Attempt to remove the symbolic link.
-		err = os.Remove(symlinkPath)
-		if err != nil {
-			// If removing the symlink fails, inform the user but proceed with uninstallation.
-			fmt.Printf("Error removing symlink: %v\n", err)
-		} else {
-			// Inform the user that the symlink has been removed successfully.
-			fmt.Printf("Removed symlink: %s\n", symlinkPath)
+		// Older databases may hold several packages with the same name; refuse to guess.
+		if len(matches) > 1 {
+			fmt.Printf("Several installed packages are named %s. Uninstall one of them by UUID:\n", packageName)
+			for _, p := range matches {
+				fmt.Printf("  %s  %s  %s\n", p.UUID, displayVersion(p.Version), p.InstallPath)
+			}
+			os.Exit(1)
 		}
+		targetPackage := &matches[0]
 
-		// Attempt to remove the associated .desktop file.
-		err = pkg.RemoveDesktopFile(packageName)
-		if err != nil {
-			// If removing the .desktop file fails, inform the user but proceed.
-			fmt.Printf("Error removing .desktop file: %v\n", err)
-		} else {
-			// Inform the user that the .desktop file has been removed successfully.
-			fmt.Printf("Removed .desktop file for package: %s\n", packageName)
+		// Every removal is staged in a transaction: files are moved aside and only deleted
+		// once the whole uninstall has succeeded, so a failure or an interrupt puts the
+		// package back exactly as it was.
+		tx := beginTransaction()
+
+		// Remove the symlinks, .desktop entry and any other files the package owns outside
+		// its installation directory; one that is already gone is not an error. A file that
+		// another package or the administrator has since replaced is left in place.
+		for _, owned := range targetPackage.ExternalFiles(cfg) {
+			if err := targetPackage.CheckOwnership(cfg, owned); err != nil {
+				if !os.IsNotExist(err) {
+					fmt.Printf("Warning: %v; leaving it in place.\n", err)
+				}
+				continue
+			}
+			switch err := tx.Remove(owned.Path); {
+			case os.IsNotExist(err):
+			case err != nil:
+				abortTransaction(tx, "Error removing %s: %v\n", owned.Path, err)
+			case owned.Type == pkg.OwnedSymlink:
+				fmt.Printf("Removed symlink: %s\n", owned.Path)
+			default:
+				fmt.Printf("Removed file: %s\n", owned.Path)
+			}
 		}
 
-		// Attempt to remove the installation directory and all its contents.
-		err = os.RemoveAll(targetPackage.InstallPath)
-		if err != nil {
-			// If removing the installation directory fails, inform the user but proceed.
-			fmt.Printf("Error removing installation directory: %v\n", err)
-		} else {
-			// Inform the user that the installation directory has been removed successfully.
-			fmt.Printf("Removed installation directory: %s\n", targetPackage.InstallPath)
+		// Attempt to remove the installation directory of the package and of each retained generation.
+		for _, installPath := range targetPackage.InstallPaths() {
+			err := tx.Remove(installPath)
+			if os.IsNotExist(err) {
+				fmt.Printf("Installation directory %s is already gone.\n", installPath)
+			} else if err != nil {
+				abortTransaction(tx, "Error removing installation directory: %v\n", err)
+			} else {
+				// Inform the user that the installation directory has been removed successfully.
+				fmt.Printf("Removed installation directory: %s\n", installPath)
+			}
 		}
 
 		// Attempt to remove the package entry from the PackageManager's tracking system.
-		err = pm.RemovePackage(targetPackage.UUID)
-		if err != nil {
-			// If removing the package from tracking fails, inform the user and exit with an error.
-			fmt.Printf("Error removing package from PackageManager: %v\n", err)
-			os.Exit(1)
+		if err := tx.Preserve(pm.PackagesFile); err != nil {
+			abortTransaction(tx, "Error: %v\n", err)
+		}
+		if err := pm.RemovePackage(targetPackage.UUID); err != nil {
+			abortTransaction(tx, "Error removing package from PackageManager: %v\n", err)
 		}
 
+		// Every step succeeded; delete the files that were moved aside.
+		tx.Commit()
+
 		// Inform the user that the package has been uninstalled successfully.
-		fmt.Printf("Package '%s' uninstalled successfully.\n", packageName)
+		fmt.Printf("Package '%s' uninstalled successfully.\n", targetPackage.Name)
 
-		// Attempt to terminate the AGS bus to refresh desktop entries.
-		killCmd := exec.Command("ags", "quit")
-		err = killCmd.Run()
-		if err != nil {
-			// If killing the AGS bus fails, inform the user but do not treat it as a critical error.
-			fmt.Printf("Error killing AGS bus: %v\n", err)
-		} else {
-			// Inform the user that the AGS bus has been terminated successfully.
-			fmt.Println("AGS bus killed successfully.")
-		}
+		// Run the refresh hooks, e.g. so that launchers pick up desktop entry changes.
+		runRefreshHooks(cfg)
 	},
 }
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the upgrade command.
+var (
+	upgradeName           string
+	upgradeVersion        string
+	upgradeAllowDowngrade bool
+
+	upgradeOnConflict string
+
+	upgradeKeepGenerations int
+)
+
+// UpgradeCmd represents the 'upgrade' command for the PackageManager.
+// It replaces an installed package with the contents of a newer archive. The new
+// payload is extracted alongside the old one and the symlinks and desktop entry are
+// switched over atomically. The old installation is then retained as a generation for
+// rollback, and generations beyond the retention limit are removed.
+var UpgradeCmd = &cobra.Command{
+	Use:   "upgrade [archive|url]",
+	Short: "Upgrade an installed package from a newer archive",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Retrieve the path or URL of the archive from the command arguments.
+		archivePath := args[0]
+
+		// Verify that a local archive exists; URLs are downloaded once the database is locked.
+		if _, err := os.Stat(archivePath); !pkg.IsURL(archivePath) && os.IsNotExist(err) {
+			fmt.Printf("Error: Archive %s does not exist.\n", archivePath)
+			os.Exit(1)
+		}
+
+		// Resolve the extraction limits from the command-line flags.
+		limits, err := parseExtractLimits()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := validateConflictPolicy(upgradeOnConflict); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Load the package database for the selected scope (per-user or system-wide).
+		cfg, pm, lock := openPackageManager(pkg.ExclusiveLock)
+		defer lock.Release()
+
+		// Download the archive into the cache if a URL was given and verify its checksum and
+		// signature, then identify its format from its magic bytes before doing any work.
+		source := archivePath
+		archivePath = fetchArchive(cfg, source)
+		digest, verified := verifyArchive(source, archivePath, nil)
+		signer := checkSignature(cfg, source, archivePath)
+		format, err := pkg.DetectArchiveFormat(archivePath)
+		if err != nil {
+			fmt.Printf("Error reading archive: %v\n", err)
+			os.Exit(1)
+		}
+		if format == pkg.FormatUnknown {
+			fmt.Printf("Error: %s is not a supported archive (expected a tarball or zip).\n", archivePath)
+			os.Exit(1)
+		}
+
+		// Derive a name and version from the archive filename as fallbacks.
+		archiveName, archiveVersion := pkg.SplitNameVersion(packageNameFromArchive(archivePath))
+
+		// Every change from here on is undone if a step fails or the upgrade is interrupted,
+		// leaving the old installation untouched.
+		tx := beginTransaction()
+		abort := func(message string, a ...interface{}) {
+			abortTransaction(tx, message, a...)
+		}
+
+		// Extract the new payload next to the existing installation.
+		installUUID := uuid.New().String()
+		installPath := filepath.Join(cfg.StoreDir, fmt.Sprintf("%s-%s", installUUID, archiveName))
+		tx.OnRollback("remove "+installPath, func() error { return os.RemoveAll(installPath) })
+		fmt.Printf("Extracting %s (%s) to %s...\n", archivePath, format, installPath)
+		report, err := pkg.ExtractArchive(archivePath, installPath, limits)
+		if err != nil {
+			abort("Error extracting archive: %v\n", err)
+		}
+		printExtractReport(report)
+
+		// Look for a package manifest in the new payload.
+		manifest, packageRoot, err := pkg.LoadManifest(installPath)
+		if err != nil {
+			abort("Error reading package manifest: %v\n", err)
+		}
+
+		// Work out which installed package is being upgraded.
+		packageName := upgradeName
+		if packageName == "" && manifest != nil {
+			packageName = manifest.Name
+		}
+		if packageName == "" {
+			packageName = archiveName
+		}
+		oldPackage := pm.FindPackage(packageName)
+		if oldPackage == nil {
+			abort("Error: package '%s' is not installed. Use --name to choose the package to upgrade.\n", packageName)
+		}
+		previous := *oldPackage
+
+		// The key that signed the archive must be trusted for the package being upgraded.
+		signed, err := checkSigner(cfg, signer, packageName)
+		if err != nil {
+			abort("Error: %v\n", err)
+		}
+
+		// Compare versions so that accidental downgrades and reinstalls are caught.
+		newVersion := resolveVersion(manifest, upgradeVersion, archiveVersion)
+		if newVersion != "" && previous.Version != "" && !upgradeAllowDowngrade {
+			switch c := pkg.CompareVersions(newVersion, previous.Version); {
+			case c == 0:
+				abort("Error: %s %s is already installed. Use --allow-downgrade to reinstall it.\n", packageName, newVersion)
+			case c < 0:
+				abort("Error: %s is older than the installed %s. Use --allow-downgrade to downgrade.\n", newVersion, previous.Version)
+			}
+		}
+		fmt.Printf("Upgrading %s from %s to %s\n", packageName, displayVersion(previous.Version), displayVersion(newVersion))
+
+		// Choose the executables in the new payload.
+		prompt := newCommandPrompter(cfg)
+		var linkedExecutables []string
+		if manifest != nil && len(manifest.Executables) > 0 {
+			linkedExecutables = manifest.ExecutablePaths(packageRoot)
+		} else if executable := matchPreviousExecutable(previous, installPath); executable != "" {
+			// Reuse the executable at the same relative location as in the previous version.
+			fmt.Printf("Using executable at the previous location: %s\n", filepath.Base(executable))
+			linkedExecutables = []string{executable}
+		} else {
+			executable, err := selectExecutable(prompt, installPath)
+			if err != nil {
+				abort("Error: %v\n", err)
+			}
+			linkedExecutables = []string{executable}
+		}
+
+		// Build the replacement package record and switch the package over to it. The
+		// previous installation is retained as a generation so that it can be rolled back to.
+		newPackage := buildPackageRecord(installUUID, packageName, installPath, newVersion, linkedExecutables, manifest, packageRoot)
+		newPackage.NoDesktop = previous.NoDesktop
+		newPackage.LinkNames = maps.Clone(previous.LinkNames)
+		recordArchive(&newPackage, source, digest, verified)
+		if signed {
+			newPackage.SignedBy = signer.ID
+		}
+
+		// New executables may collide with other packages or unmanaged files.
+		proceed, err := resolveConflicts(prompt, pm, cfg, &newPackage, upgradeOnConflict)
+		if err != nil {
+			abort("Error: %v\n", err)
+		}
+		if !proceed {
+			abort("Upgrade aborted because of conflicts.\n")
+		}
+		if newPackage.Name != packageName {
+			abort("Error: an upgrade cannot rename %s; resolve the name conflict by uninstalling the other package.\n", packageName)
+		}
+		if err := replaceCurrentGeneration(tx, pm, cfg, previous, newPackage, upgradeKeepGenerations); err != nil {
+			abort("Error: %v\n", err)
+		}
+		tx.Commit()
+
+		fmt.Printf("Package '%s' upgraded successfully.\n", packageName)
+
+		// Run the refresh hooks, e.g. so that launchers pick up desktop entry changes.
+		runRefreshHooks(cfg)
+	},
+}
+
+func init() {
+	UpgradeCmd.Flags().StringVar(&upgradeName, "name", "", "name of the installed package to upgrade (defaults to the manifest or archive filename)")
+	UpgradeCmd.Flags().StringVar(&upgradeVersion, "version", "", "version of the new package (defaults to the manifest or archive filename)")
+	UpgradeCmd.Flags().BoolVar(&upgradeAllowDowngrade, "allow-downgrade", false, "allow reinstalling the same version or downgrading")
+	addPromptFlags(UpgradeCmd)
+	UpgradeCmd.Flags().IntVar(&upgradeKeepGenerations, "keep-generations", pkg.DefaultRetainedGenerations, "number of previous installations to retain for rollback")
+	addChecksumFlags(UpgradeCmd)
+	addSignatureFlags(UpgradeCmd)
+	addConflictFlag(UpgradeCmd, &upgradeOnConflict)
+	addDownloadFlags(UpgradeCmd)
+	addExtractLimitFlags(UpgradeCmd)
+}
+
+// matchPreviousExecutable returns the executable in the new payload at the same relative
+// path as the previous version's main executable, or an empty string if there is none.
+func matchPreviousExecutable(previous pkg.Package, installPath string) string {
+	relPath, err := filepath.Rel(previous.InstallPath, previous.Executable)
+	if err != nil {
+		return ""
+	}
+
+	candidates := []string{filepath.Join(installPath, relPath)}
+
+	// Archives usually wrap everything in a versioned top-level directory (e.g. "app-1.2/"),
+	// so also look for the same path beneath each top-level directory of the new payload.
+	parts := strings.SplitN(relPath, string(filepath.Separator), 2)
+	if len(parts) == 2 {
+		entries, _ := os.ReadDir(installPath)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				candidates = append(candidates, filepath.Join(installPath, entry.Name(), parts[1]))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err == nil && info.Mode().IsRegular() && isExecutable(candidate, info) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// displayVersion renders a possibly unknown version for messages.
+func displayVersion(version string) string {
+	if version == "" {
+		return "an unknown version"
+	}
+	return version
+}
+
+// displayTime renders when an installation was made for messages and info output.
+// Records written before install times were kept have the zero time, which is shown
+// as "unknown" wherever an install time appears.
+func displayTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Beans69584/PackageManager/pkg"
+	"github.com/spf13/cobra"
+)
+
+// VerifyCmd represents the 'verify' command for the PackageManager.
+// It re-hashes the files of installed packages against the checksums recorded at install
+// time, and checks that their symlinks and .desktop entries still exist and point where
+// the package record says. It exits with a non-zero status if anything has drifted.
+var VerifyCmd = &cobra.Command{
+	Use:   "verify [package_name]",
+	Short: "Check installed packages for modified or missing files",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Load the package database for the selected scope (per-user or system-wide).
+		cfg, pm, lock := openPackageManager(pkg.SharedLock)
+		defer lock.Release()
+
+		// Verify the named package, or every installed package.
+		packages := pm.Packages
+		if len(args) == 1 {
+			targetPackage := pm.FindPackage(args[0])
+			if targetPackage == nil {
+				fmt.Printf("Package %s not found.\n", args[0])
+				os.Exit(1)
+			}
+			packages = []pkg.Package{*targetPackage}
+		}
+		if len(packages) == 0 {
+			fmt.Println("No packages installed.")
+			return
+		}
+
+		drifted := 0
+		for _, p := range packages {
+			problems, checked := pkg.VerifyPackage(p, cfg)
+			if len(problems) == 0 {
+				fmt.Printf("%s %s: OK (%d files checked)\n", p.Name, displayVersion(p.Version), checked)
+				continue
+			}
+
+			drifted++
+			fmt.Printf("%s %s: %d problem(s) in %d files checked\n", p.Name, displayVersion(p.Version), len(problems), checked)
+			for _, problem := range problems {
+				fmt.Printf("  %s: %s\n", problem.Path, problem.Reason)
+			}
+		}
+
+		if drifted > 0 {
+			fmt.Printf("%d of %d package(s) failed verification.\n", drifted, len(packages))
+			os.Exit(1)
+		}
+	},
+}
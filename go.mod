@@ -3,8 +3,18 @@ module github.com/Beans69584/PackageManager
 go 1.23.2
 
 require (
+	aead.dev/minisign v0.2.0
+	github.com/BurntSushi/toml v1.6.0
 	github.com/google/uuid v1.6.0
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/klauspost/compress v1.18.0
 	github.com/spf13/cobra v1.8.1
+	github.com/ulikunitz/xz v0.5.12
+	golang.org/x/term v0.30.0
+)
+
+require (
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
 )
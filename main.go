@@ -9,13 +9,6 @@ import (
 )
 
 func main() {
-	// Check if the program is running with root privileges.
-	// Package installation and system modifications typically require elevated permissions.
-	if os.Geteuid() != 0 {
-		fmt.Println("You need to have root privileges to run this program.")
-		os.Exit(1)
-	}
-
 	// Define the root command for the CLI application using Cobra.
 	// This command acts as the base for all subcommands like install, uninstall, and list.
 	rootCmd := &cobra.Command{
@@ -24,11 +17,25 @@ func main() {
 		Long:  `PackageManager is a simple tool to install, uninstall, and manage software packages.`,
 	}
 
+	// Register the global --user/--system scope flags and configuration overrides. Root
+	// privileges are only required for system-wide operations; without root, packages are
+	// managed in the user's home directory.
+	cmd.AddConfigFlags(rootCmd)
+
 	// Add subcommands to the root command.
 	// These subcommands are defined in the 'cmd' package and handle specific package management tasks.
 	rootCmd.AddCommand(cmd.InstallCmd)
 	rootCmd.AddCommand(cmd.UninstallCmd)
 	rootCmd.AddCommand(cmd.ListCmd)
+	rootCmd.AddCommand(cmd.SearchCmd)
+	rootCmd.AddCommand(cmd.InfoCmd)
+	rootCmd.AddCommand(cmd.UpgradeCmd)
+	rootCmd.AddCommand(cmd.RollbackCmd)
+	rootCmd.AddCommand(cmd.VerifyCmd)
+	rootCmd.AddCommand(cmd.DoctorCmd)
+	rootCmd.AddCommand(cmd.KeyCmd)
+	rootCmd.AddCommand(cmd.RepoCmd)
+	rootCmd.AddCommand(cmd.UpdateCmd)
 
 	// Execute the root command, which parses the CLI input and invokes the appropriate subcommand.
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain lets the tests run the test binary as packagemanager itself, so that commands
+// are exercised end to end, including the ones that exit the process.
+func TestMain(m *testing.M) {
+	if os.Getenv("PACKAGEMANAGER_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runPackageManager runs packagemanager with args and a home directory of its own,
+// failing the test if it exits with an error.
+func runPackageManager(t *testing.T, home string, args ...string) string {
+	t.Helper()
+	output, err := packageManagerCommand(home, args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("packagemanager %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+// packageManagerCommand prepares packagemanager to run with args and a home directory of its own.
+func packageManagerCommand(home string, args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = []string{
+		"PACKAGEMANAGER_TEST_MAIN=1",
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"XDG_CONFIG_HOME=" + filepath.Join(home, ".config"),
+		"XDG_DATA_HOME=" + filepath.Join(home, ".local", "share"),
+		"XDG_CACHE_HOME=" + filepath.Join(home, ".cache"),
+	}
+	return cmd
+}
+
+// writeToolArchive writes a gzipped tarball holding a single executable.
+func writeToolArchive(t *testing.T, archivePath string) {
+	t.Helper()
+	writeArchive(t, archivePath, "pmroottest")
+}
+
+// writeArchive writes a gzipped tarball holding a shell script for each executable name.
+func writeArchive(t *testing.T, archivePath string, executables ...string) {
+	t.Helper()
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, name := range executables {
+		script := "#!/bin/sh\necho " + name + "\n"
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(script))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(script)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstallAndUninstallInAlternateRoot(t *testing.T) {
+	root := t.TempDir()
+	home := t.TempDir()
+	archivePath := filepath.Join(t.TempDir(), "pmroottest-1.0.tar.gz")
+	writeToolArchive(t, archivePath)
+
+	runPackageManager(t, home, "--root", root, "install", archivePath, "--name", "pmroottest", "--exec", "pmroottest", "--yes")
+
+	// Everything lands in the system-wide locations beneath the root.
+	storeDir := filepath.Join(root, "usr", "local", "share", "packagemanager")
+	linkPath := filepath.Join(root, "usr", "local", "bin", "pmroottest")
+	desktopPath := filepath.Join(root, "usr", "share", "applications", "pmroottest.desktop")
+	databasePath := filepath.Join(storeDir, "packages.json")
+
+	installs, err := filepath.Glob(filepath.Join(storeDir, "*-pmroottest"))
+	if err != nil || len(installs) != 1 {
+		t.Fatalf("found installation directories %v (%v), want one", installs, err)
+	}
+	if _, err := os.Stat(filepath.Join(installs[0], "pmroottest")); err != nil {
+		t.Errorf("executable not extracted: %v", err)
+	}
+	database, err := os.ReadFile(databasePath)
+	if err != nil || !strings.Contains(string(database), `"pmroottest"`) {
+		t.Errorf("database does not record the package: %v\n%s", err, database)
+	}
+
+	// The symlink and .desktop entry point at the path the executable will have once the
+	// root is booted, not at its current location on the host.
+	imagePath := strings.TrimPrefix(filepath.Join(installs[0], "pmroottest"), root)
+	target, err := os.Readlink(linkPath)
+	if err != nil || target != imagePath {
+		t.Errorf("symlink %s -> %q (%v), want %q", linkPath, target, err, imagePath)
+	}
+	desktop, err := os.ReadFile(desktopPath)
+	if err != nil || !strings.Contains(string(desktop), "Exec="+imagePath) || strings.Contains(string(desktop), root) {
+		t.Errorf("desktop entry does not run %s: %v\n%s", imagePath, err, desktop)
+	}
+
+	// Nothing is written to the host: not the home directory, nor the real system locations.
+	assertEmptyDir(t, home)
+	for _, hostPath := range []string{
+		"/usr/local/bin/pmroottest",
+		"/usr/share/applications/pmroottest.desktop",
+		filepath.Join("/usr/local/share/packagemanager", filepath.Base(installs[0])),
+	} {
+		if _, err := os.Lstat(hostPath); err == nil {
+			t.Errorf("install wrote %s on the host", hostPath)
+		}
+	}
+
+	runPackageManager(t, home, "--root", root, "uninstall", "pmroottest")
+
+	for _, path := range []string{installs[0], linkPath, desktopPath} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("uninstall left %s behind", path)
+		}
+	}
+	database, err = os.ReadFile(databasePath)
+	if err != nil || strings.Contains(string(database), `"pmroottest"`) {
+		t.Errorf("database still records the package: %v\n%s", err, database)
+	}
+	assertEmptyDir(t, home)
+}
+
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("unexpected %s in %s", entry.Name(), dir)
+	}
+}
+
+func TestRollbackRefusesLinkOwnedByAnotherPackage(t *testing.T) {
+	root := t.TempDir()
+	home := t.TempDir()
+	archives := t.TempDir()
+	for _, archive := range []struct {
+		name        string
+		executables []string
+	}{
+		{"tool-1.0.tar.gz", []string{"tool", "extra"}},
+		{"tool-2.0.tar.gz", []string{"tool"}},
+		{"other-1.0.tar.gz", []string{"extra"}},
+	} {
+		writeArchive(t, filepath.Join(archives, archive.name), archive.executables...)
+	}
+
+	// Version 1.0 links "extra", which 2.0 drops and another package then claims.
+	runPackageManager(t, home, "--root", root, "install", filepath.Join(archives, "tool-1.0.tar.gz"), "--exec", "tool", "--exec", "extra", "--no-desktop")
+	runPackageManager(t, home, "--root", root, "upgrade", filepath.Join(archives, "tool-2.0.tar.gz"))
+	runPackageManager(t, home, "--root", root, "install", filepath.Join(archives, "other-1.0.tar.gz"), "--exec", "extra", "--no-desktop")
+
+	linkPath := filepath.Join(root, "usr", "local", "bin", "extra")
+	before, err := os.Readlink(linkPath)
+	if err != nil || !strings.Contains(before, "-other/") {
+		t.Fatalf("extra -> %q (%v), want the other package's executable", before, err)
+	}
+
+	output, err := packageManagerCommand(home, "--root", root, "rollback", "tool").CombinedOutput()
+	if err == nil {
+		t.Fatalf("rollback took over another package's symlink:\n%s", output)
+	}
+	if !strings.Contains(string(output), "owned by package other") {
+		t.Errorf("rollback did not name the conflict:\n%s", output)
+	}
+	if after, err := os.Readlink(linkPath); err != nil || after != before {
+		t.Errorf("extra -> %q (%v) after the refused rollback, want %q", after, err, before)
+	}
+	if database, _ := os.ReadFile(filepath.Join(root, "usr", "local", "share", "packagemanager", "packages.json")); !strings.Contains(string(database), `"version": "2.0"`) {
+		t.Errorf("refused rollback changed the current version:\n%s", database)
+	}
+}
+
+func TestDoctorFixKeepsLookalikeFiles(t *testing.T) {
+	root := t.TempDir()
+	home := t.TempDir()
+	archivePath := filepath.Join(t.TempDir(), "pmroottest-1.0.tar.gz")
+	writeToolArchive(t, archivePath)
+	runPackageManager(t, home, "--root", root, "install", archivePath, "--name", "pmroottest", "--exec", "pmroottest", "--yes")
+
+	storeDir := filepath.Join(root, "usr", "local", "share", "packagemanager")
+	binDir := filepath.Join(root, "usr", "local", "bin")
+	leftovers := []string{
+		filepath.Join(binDir, ".pmroottest.tmp-1234"),
+		filepath.Join(storeDir, ".packages.json.tmp-987654321"),
+		filepath.Join(storeDir, ".1-pmroottest.removed-1234-0"),
+	}
+	lookalikes := []string{
+		filepath.Join(binDir, "foo.tmp-old"),
+		filepath.Join(binDir, "build.removed-2"),
+		filepath.Join(binDir, ".cache.tmp-"),
+		filepath.Join(storeDir, "notes.tmp-1"),
+	}
+	for _, path := range append(append([]string{}, leftovers...), lookalikes...) {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runPackageManager(t, home, "--root", root, "doctor", "--fix")
+
+	for _, path := range leftovers {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("doctor --fix left %s behind", path)
+		}
+	}
+	for _, path := range lookalikes {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("doctor --fix removed the user's %s: %v", path, err)
+		}
+	}
+}
+
+func TestMissingInstallTimesShownAsUnknown(t *testing.T) {
+	root := t.TempDir()
+	home := t.TempDir()
+	archives := t.TempDir()
+	for _, name := range []string{"tool-1.0.tar.gz", "tool-2.0.tar.gz"} {
+		writeArchive(t, filepath.Join(archives, name), "tool")
+	}
+	runPackageManager(t, home, "--root", root, "install", filepath.Join(archives, "tool-1.0.tar.gz"), "--exec", "tool", "--no-desktop")
+	runPackageManager(t, home, "--root", root, "upgrade", filepath.Join(archives, "tool-2.0.tar.gz"))
+
+	stripInstallTimes(t, filepath.Join(root, "usr", "local", "share", "packagemanager", "packages.json"))
+
+	output, err := packageManagerCommand(home, "--root", root, "rollback", "tool", "--to", "9.9").CombinedOutput()
+	if err == nil {
+		t.Fatalf("rollback to a version that was never installed succeeded:\n%s", output)
+	}
+	if !strings.Contains(string(output), "1.0 (installed unknown)") {
+		t.Errorf("rollback did not list the retained generation as installed at an unknown time:\n%s", output)
+	}
+
+	// info shows a missing install time the same way, for the package and its generations.
+	info := runPackageManager(t, home, "--root", root, "info", "tool")
+	if strings.Count(info, "unknown") != 2 || strings.Contains(info, "0001-01-01") {
+		t.Errorf("info did not show the missing install times as unknown:\n%s", info)
+	}
+}
+
+// stripInstallTimes removes the install times from a database, as in records written
+// before they were kept.
+func stripInstallTimes(t *testing.T, databasePath string) {
+	t.Helper()
+	database, err := os.ReadFile(databasePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packages []map[string]any
+	if err := json.Unmarshal(database, &packages); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packages {
+		delete(p, "installed_at")
+		for _, generation := range p["generations"].([]any) {
+			delete(generation.(map[string]any), "installed_at")
+		}
+	}
+	if database, err = json.Marshal(packages); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(databasePath, database, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
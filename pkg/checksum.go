@@ -0,0 +1,265 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Checksum algorithms supported for archive verification.
+const (
+	SHA256 = "sha256"
+	SHA512 = "sha512"
+)
+
+// checksumFiles maps the conventional names of checksum files published next to
+// release archives to the algorithm they use, in the order they are looked for.
+var checksumFiles = []struct {
+	name      string
+	algorithm string
+}{
+	{"SHA256SUMS", SHA256},
+	{"SHA512SUMS", SHA512},
+}
+
+// Digest is a checksum of an archive, such as "sha256:9f86d0...".
+type Digest struct {
+	Algorithm string // SHA256 or SHA512.
+	Hex       string // The lowercase hex-encoded checksum.
+}
+
+// String formats the digest as "<algorithm>:<hex>".
+func (d Digest) String() string {
+	return d.Algorithm + ":" + d.Hex
+}
+
+// NewDigest validates a hex-encoded checksum for an algorithm.
+//
+// Parameters:
+//   - algorithm (string): SHA256 or SHA512.
+//   - hexDigest (string): The checksum, in upper or lower case.
+//
+// Returns:
+//   - Digest: The checksum.
+//   - error: An error object if the algorithm is unknown or the checksum has the wrong length, otherwise nil.
+func NewDigest(algorithm, hexDigest string) (Digest, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return Digest{}, err
+	}
+	hexDigest = strings.ToLower(strings.TrimSpace(hexDigest))
+	raw, err := hex.DecodeString(hexDigest)
+	if err != nil || len(raw) != h.Size() {
+		return Digest{}, fmt.Errorf("%q is not a valid %s checksum", hexDigest, algorithm)
+	}
+	return Digest{Algorithm: algorithm, Hex: hexDigest}, nil
+}
+
+// ParseDigest reads a checksum in the "<algorithm>:<hex>" form produced by Digest.String.
+//
+// Parameters:
+//   - s (string): The checksum, e.g. "sha256:9f86d0...".
+//
+// Returns:
+//   - Digest: The checksum.
+//   - error: An error object if s has no algorithm prefix or is not a valid checksum, otherwise nil.
+func ParseDigest(s string) (Digest, error) {
+	algorithm, hexDigest, ok := strings.Cut(s, ":")
+	if !ok {
+		return Digest{}, fmt.Errorf("checksum %q must have the form <algorithm>:<hex>", s)
+	}
+	return NewDigest(strings.ToLower(algorithm), hexDigest)
+}
+
+// FileDigest computes the checksum of a file.
+//
+// Parameters:
+//   - path (string): The path of the file.
+//   - algorithm (string): SHA256 or SHA512.
+//
+// Returns:
+//   - Digest: The checksum of the file's contents.
+//   - error: An error object if the file cannot be read, otherwise nil.
+func FileDigest(path, algorithm string) (Digest, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return Digest{}, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return Digest{}, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return Digest{}, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return Digest{Algorithm: algorithm, Hex: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// VerifyFile checks that a file has the expected checksum.
+//
+// Parameters:
+//   - path (string): The path of the file.
+//   - expected (Digest): The checksum the file must have.
+//
+// Returns:
+//   - error: An error object describing the mismatch, or if the file cannot be read, otherwise nil.
+func VerifyFile(path string, expected Digest) error {
+	actual, err := FileDigest(path, expected.Algorithm)
+	if err != nil {
+		return err
+	}
+	if actual.Hex != expected.Hex {
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s", expected.Algorithm, filepath.Base(path), expected.Hex, actual.Hex)
+	}
+	return nil
+}
+
+// FindPublishedChecksum looks for SHA256SUMS and SHA512SUMS files next to an archive,
+// in the same local directory or at the same URL, and returns the checksum listed for
+// the archive by the first of them that lists it.
+//
+// Parameters:
+//   - source (string): The archive path or URL given by the user.
+//   - fileNames ([]string): The names the archive may be listed under.
+//   - timeout (time.Duration): How long to wait for a remote checksum file.
+//
+// Returns:
+//   - Digest: The published checksum, or the zero Digest if no checksum file lists the archive.
+//   - string: Where the checksum was found; if checksum files exist but none lists the archive, the comma-separated locations checked; otherwise "".
+//   - error: An error object if a checksum file exists but cannot be read, otherwise nil. If a server
+//     would not say whether a checksum file exists and no other file lists the archive, the error is an *UnavailableError.
+func FindPublishedChecksum(source string, fileNames []string, timeout time.Duration) (Digest, string, error) {
+	var checked []string
+	var unavailable *UnavailableError
+	for _, candidate := range checksumFiles {
+		var location string
+		var data []byte
+		var err error
+		if IsURL(source) {
+			location, data, err = fetchChecksumFile(source, candidate.name, timeout)
+		} else {
+			location = filepath.Join(filepath.Dir(source), candidate.name)
+			data, err = os.ReadFile(location)
+			if os.IsNotExist(err) {
+				data, err = nil, nil
+			}
+		}
+		if errors.As(err, &unavailable) {
+			// Another checksum file may still list the archive.
+			continue
+		}
+		if err != nil {
+			return Digest{}, location, fmt.Errorf("error reading %s: %v", location, err)
+		}
+		if data == nil {
+			continue
+		}
+
+		for _, name := range fileNames {
+			if hexDigest, ok := lookupChecksum(data, name); ok {
+				digest, err := NewDigest(candidate.algorithm, hexDigest)
+				if err != nil {
+					return Digest{}, location, fmt.Errorf("invalid entry for %s in %s: %v", name, location, err)
+				}
+				return digest, location, nil
+			}
+		}
+		// Not listed here; another checksum file may still list it.
+		checked = append(checked, location)
+	}
+	if unavailable != nil {
+		return Digest{}, strings.Join(checked, ", "), unavailable
+	}
+	return Digest{}, strings.Join(checked, ", "), nil
+}
+
+// fetchChecksumFile downloads a checksum file from the same directory as an archive URL.
+// A missing file is not an error and returns nil data.
+func fetchChecksumFile(archiveURL, name string, timeout time.Duration) (string, []byte, error) {
+	u, err := url.Parse(archiveURL)
+	if err != nil {
+		return archiveURL, nil, err
+	}
+	u.Path = path.Join(path.Dir(u.Path), name)
+	u.RawQuery, u.Fragment = "", ""
+	location := u.String()
+
+	data, err := fetchSmallFile(location, timeout)
+	return location, data, err
+}
+
+// UnavailableError reports that a server answered a request for a file published alongside
+// an archive with neither the file nor a sign that it does not exist.
+type UnavailableError struct {
+	Location string // The URL of the file.
+	Status   string // The status the server returned.
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s is unavailable (server returned %s)", e.Location, e.Status)
+}
+
+// fetchSmallFile downloads a file published alongside an archive, such as a checksum
+// file or signature. A missing file is not an error and returns nil data; hosts that
+// forbid listing unpublished files answer 403 rather than 404, so that counts as missing
+// too. Any other unexpected status returns an *UnavailableError.
+func fetchSmallFile(location string, timeout time.Duration) ([]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return nil, nil
+	default:
+		return nil, &UnavailableError{Location: location, Status: resp.Status}
+	}
+
+	// These files are small; refuse anything that is clearly not one.
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// lookupChecksum finds the checksum of name in the output of sha256sum or sha512sum,
+// whose lines read "<hex>  <name>", or "<hex> *<name>" for binary mode.
+func lookupChecksum(data []byte, name string) (string, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		listed := strings.TrimPrefix(strings.TrimPrefix(fields[1], "*"), "./")
+		if listed == name {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
+
+// newHash returns a hash for a checksum algorithm.
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+}
@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindPublishedChecksumTriesEveryChecksumFile(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool-1.0.tar.gz")
+	if err := os.WriteFile(archivePath, []byte("archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sha512, err := FileDigest(archivePath, SHA512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SHA256SUMS only covers another release; SHA512SUMS lists the archive.
+	other := strings.Repeat("0", 64) + "  tool-0.9.tar.gz\n"
+	if err := os.WriteFile(filepath.Join(dir, "SHA256SUMS"), []byte(other), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "SHA512SUMS"), []byte(sha512.Hex+"  tool-1.0.tar.gz\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	digest, location, err := FindPublishedChecksum(archivePath, []string{"tool-1.0.tar.gz"}, time.Second)
+	if err != nil {
+		t.Fatalf("FindPublishedChecksum: %v", err)
+	}
+	if digest != sha512 || location != filepath.Join(dir, "SHA512SUMS") {
+		t.Errorf("found %v in %s, want %v in SHA512SUMS", digest, location, sha512)
+	}
+
+	// Once neither file lists the archive, both are reported as checked.
+	if err := os.WriteFile(filepath.Join(dir, "SHA512SUMS"), []byte(other), 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest, location, err = FindPublishedChecksum(archivePath, []string{"tool-1.0.tar.gz"}, time.Second)
+	if err != nil {
+		t.Fatalf("FindPublishedChecksum: %v", err)
+	}
+	want := filepath.Join(dir, "SHA256SUMS") + ", " + filepath.Join(dir, "SHA512SUMS")
+	if digest.Hex != "" || location != want {
+		t.Errorf("found %v in %q, want no checksum and %q", digest, location, want)
+	}
+}
+
+func TestFindPublishedChecksumHandlesServerStatuses(t *testing.T) {
+	content := []byte("archive")
+	archivePath := filepath.Join(t.TempDir(), "tool-1.0.tar.gz")
+	if err := os.WriteFile(archivePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sha512, err := FileDigest(archivePath, SHA512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		sha256Status    int
+		sha512Status    int
+		wantDigest      bool
+		wantUnavailable bool
+	}{
+		{"forbidden", http.StatusForbidden, http.StatusForbidden, false, false},
+		{"not found", http.StatusNotFound, http.StatusNotFound, false, false},
+		{"gone", http.StatusGone, http.StatusGone, false, false},
+		{"other file listed", http.StatusInternalServerError, http.StatusOK, true, false},
+		{"server error", http.StatusInternalServerError, http.StatusNotFound, false, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch path.Base(r.URL.Path) {
+				case "SHA256SUMS":
+					w.WriteHeader(tc.sha256Status)
+				case "SHA512SUMS":
+					w.WriteHeader(tc.sha512Status)
+					if tc.sha512Status == http.StatusOK {
+						w.Write([]byte(sha512.Hex + "  tool-1.0.tar.gz\n"))
+					}
+				default:
+					w.Write(content)
+				}
+			}))
+			defer server.Close()
+
+			digest, _, err := FindPublishedChecksum(server.URL+"/tool-1.0.tar.gz", []string{"tool-1.0.tar.gz"}, time.Second)
+			var unavailable *UnavailableError
+			if got := errors.As(err, &unavailable); got != tc.wantUnavailable {
+				t.Fatalf("FindPublishedChecksum: %v, want unavailable %v", err, tc.wantUnavailable)
+			}
+			if !tc.wantUnavailable && err != nil {
+				t.Fatalf("FindPublishedChecksum: %v", err)
+			}
+			if got := digest == sha512; got != tc.wantDigest {
+				t.Errorf("found %v, want the SHA512SUMS checksum %v", digest, tc.wantDigest)
+			}
+		})
+	}
+}
+
+func TestFindSignatureHandlesServerStatuses(t *testing.T) {
+	tests := []struct {
+		status          int
+		wantUnavailable bool
+	}{
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, false},
+		{http.StatusGone, false},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tc := range tests {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			signature, _, err := FindSignature(server.URL+"/tool-1.0.tar.gz", "", time.Second)
+			var unavailable *UnavailableError
+			if got := errors.As(err, &unavailable); got != tc.wantUnavailable {
+				t.Fatalf("FindSignature: %v, want unavailable %v", err, tc.wantUnavailable)
+			}
+			if !tc.wantUnavailable && err != nil {
+				t.Fatalf("FindSignature: %v", err)
+			}
+			if signature != nil {
+				t.Errorf("found signature %q", signature)
+			}
+		})
+	}
+}
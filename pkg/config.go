@@ -0,0 +1,487 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SystemConfigPath is the system-wide configuration file, read by every user.
+const SystemConfigPath = "/etc/packagemanager/config.toml"
+
+// Scope identifies whether packages are installed for the whole system or a single user.
+type Scope int
+
+const (
+	// ScopeSystem installs packages system-wide and requires root privileges.
+	ScopeSystem Scope = iota
+	// ScopeUser installs packages into the invoking user's home directory.
+	ScopeUser
+)
+
+// String returns a human-readable name for the scope.
+func (s Scope) String() string {
+	if s == ScopeUser {
+		return "user"
+	}
+	return "system"
+}
+
+// Config holds the settings shared by every command: where a scope keeps its package
+// store, database, executable symlinks and desktop entries, and how installs behave.
+// Each scope has its own store and database.
+type Config struct {
+	Scope      Scope  // The scope these settings apply to.
+	Root       string // An alternate root directory that every path is placed under, or "".
+	StoreDir   string // The directory holding installed packages and the database.
+	BinDir     string // The directory where executable symlinks are created.
+	DesktopDir string // The directory where .desktop files are written.
+	CacheDir   string // The directory where downloaded archives are cached.
+
+	DefaultIcon    string   // The icon used in .desktop files when a package ships none.
+	RefreshHooks   []string // Commands run after packages change, e.g. to refresh a launcher.
+	NonInteractive bool     // Whether to never prompt, as with --non-interactive.
+	AssumeYes      bool     // Whether to answer confirmations with yes, as with --yes.
+
+	SignaturePolicy string   // SignaturesOptional or SignaturesRequired.
+	SourceDirs      []string // Local directories of archives that search looks through.
+}
+
+// configFile is the on-disk form of a configuration file. Every key is optional; keys
+// that are present override the values loaded before them.
+type configFile struct {
+	DefaultIcon    *string   `toml:"default_icon"`
+	RefreshHooks   *[]string `toml:"refresh_hooks"`
+	NonInteractive *bool     `toml:"non_interactive"`
+	AssumeYes      *bool     `toml:"assume_yes"`
+
+	SignaturePolicy *string   `toml:"signature_policy"`
+	SourceDirs      *[]string `toml:"source_dirs"`
+
+	System configDirs `toml:"system"` // Directories used by the system scope.
+	User   configDirs `toml:"user"`   // Directories used by the user scope.
+}
+
+// configDirs holds the directory settings of one scope in a configuration file.
+type configDirs struct {
+	StoreDir   string `toml:"store_dir"`
+	BinDir     string `toml:"bin_dir"`
+	DesktopDir string `toml:"desktop_dir"`
+	CacheDir   string `toml:"cache_dir"`
+}
+
+// DefaultConfig returns the built-in settings for a scope. The system scope uses the
+// usual /usr/local locations; the user scope follows the XDG Base Directory
+// Specification, keeping data under $XDG_DATA_HOME (default ~/.local/share), downloads
+// under $XDG_CACHE_HOME (default ~/.cache) and linking executables into $XDG_BIN_HOME
+// (default ~/.local/bin).
+//
+// Parameters:
+//   - scope (Scope): The scope to return settings for.
+//
+// Returns:
+//   - *Config: The default settings.
+//   - error: An error object if the user's home directory cannot be determined, otherwise nil.
+func DefaultConfig(scope Scope) (*Config, error) {
+	cfg := &Config{
+		Scope:        scope,
+		StoreDir:     "/usr/local/share/packagemanager",
+		BinDir:       "/usr/local/bin",
+		DesktopDir:   "/usr/share/applications",
+		CacheDir:     "/var/cache/packagemanager",
+		DefaultIcon:  "/usr/share/pixmaps/default-icon.png",
+		RefreshHooks: []string{"ags quit"},
+
+		SignaturePolicy: SignaturesOptional,
+	}
+	if scope == ScopeSystem {
+		return cfg, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("error determining home directory: %v", err)
+	}
+	dataHome := xdgDir("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	cfg.StoreDir = filepath.Join(dataHome, "packagemanager")
+	cfg.BinDir = xdgDir("XDG_BIN_HOME", filepath.Join(home, ".local", "bin"))
+	cfg.DesktopDir = filepath.Join(dataHome, "applications")
+	cfg.CacheDir = filepath.Join(xdgDir("XDG_CACHE_HOME", filepath.Join(home, ".cache")), "packagemanager")
+	return cfg, nil
+}
+
+// UserConfigPath returns the per-user configuration file,
+// $XDG_CONFIG_HOME/packagemanager/config.toml (default ~/.config/packagemanager/config.toml).
+//
+// Returns:
+//   - string: The path to the user's configuration file.
+//   - error: An error object if the user's home directory cannot be determined, otherwise nil.
+func UserConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error determining home directory: %v", err)
+	}
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", filepath.Join(home, ".config")), "packagemanager", "config.toml"), nil
+}
+
+// ConfigOverrides holds settings given for a single invocation, typically on the command
+// line. Empty fields leave the configured value unchanged.
+type ConfigOverrides struct {
+	Root       string // An alternate root directory that every path is placed under, as with --root.
+	File       string // An additional configuration file read after the others.
+	StoreDir   string // Overrides the store directory.
+	BinDir     string // Overrides the bin directory.
+	DesktopDir string // Overrides the desktop directory.
+}
+
+// LoadConfig builds the settings for a scope. Later sources override earlier ones:
+// the built-in defaults, SystemConfigPath, the user's configuration file, the file
+// named by overrides.File (or $PACKAGEMANAGER_CONFIG), the PACKAGEMANAGER_*
+// environment variables, and finally the remaining overrides. Missing configuration
+// files are ignored.
+//
+// With an alternate root, the system configuration is read from inside the root, the
+// user's configuration file is ignored, and the configured directories are placed under
+// the root once every source has been applied.
+//
+// Parameters:
+//   - scope (Scope): The scope to load settings for.
+//   - overrides (ConfigOverrides): Settings that take precedence over every other source.
+//
+// Returns:
+//   - *Config: The resolved settings.
+//   - error: An error object if a configuration file, environment variable or override is invalid, otherwise nil.
+func LoadConfig(scope Scope, overrides ConfigOverrides) (*Config, error) {
+	cfg, err := DefaultConfig(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := resolveRoot(overrides.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{filepath.Join(root, SystemConfigPath)}
+	if root == "" {
+		if userFile, err := UserConfigPath(); err == nil {
+			files = append(files, userFile)
+		}
+	}
+	extraFile := overrides.File
+	if extraFile == "" {
+		extraFile = os.Getenv("PACKAGEMANAGER_CONFIG")
+	}
+
+	for _, path := range files {
+		if err := cfg.applyFile(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if extraFile != "" {
+		// A file named explicitly must exist.
+		if err := cfg.applyFile(extraFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	for _, setting := range []struct {
+		target *string
+		value  string
+	}{
+		{&cfg.StoreDir, overrides.StoreDir},
+		{&cfg.BinDir, overrides.BinDir},
+		{&cfg.DesktopDir, overrides.DesktopDir},
+	} {
+		if setting.value != "" {
+			*setting.target = setting.value
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Move every directory under the alternate root. Paths recorded in the database,
+	// symlink targets and .desktop entries are converted back with ImagePath.
+	if root != "" {
+		cfg.Root = root
+		cfg.StoreDir = cfg.HostPath(cfg.StoreDir)
+		cfg.BinDir = cfg.HostPath(cfg.BinDir)
+		cfg.DesktopDir = cfg.HostPath(cfg.DesktopDir)
+		cfg.CacheDir = cfg.HostPath(cfg.CacheDir)
+		for i, dir := range cfg.SourceDirs {
+			cfg.SourceDirs[i] = cfg.HostPath(dir)
+		}
+	}
+	return cfg, nil
+}
+
+// resolveRoot makes an alternate root directory absolute and checks that it exists.
+// The filesystem root itself is treated as no alternate root.
+func resolveRoot(root string) (string, error) {
+	if root == "" {
+		return "", nil
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid root directory %s: %v", root, err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid root directory: %v", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid root directory: %s is not a directory", root)
+	}
+	if root == string(filepath.Separator) {
+		return "", nil
+	}
+	return root, nil
+}
+
+// HostPath places a path as seen from inside the alternate root under the root, giving
+// the path to use on the host. Without an alternate root the path is returned unchanged.
+//
+// Parameters:
+//   - path (string): An absolute path inside the root.
+//
+// Returns:
+//   - string: The corresponding path on the host.
+func (cfg *Config) HostPath(path string) string {
+	if cfg.Root == "" {
+		return path
+	}
+	return filepath.Join(cfg.Root, path)
+}
+
+// ImagePath converts a host path beneath the alternate root into the path seen from
+// inside the root, e.g. when the root is booted as an image. Paths outside the root,
+// and all paths when there is no alternate root, are returned unchanged.
+//
+// Parameters:
+//   - path (string): An absolute path on the host.
+//
+// Returns:
+//   - string: The corresponding path inside the root.
+func (cfg *Config) ImagePath(path string) string {
+	return stripRoot(cfg.Root, path)
+}
+
+// stripRoot removes root from the front of path, keeping path absolute.
+func stripRoot(root, path string) string {
+	if root == "" {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return path
+	}
+	return filepath.Join(string(filepath.Separator), rel)
+}
+
+// applyFile overlays the settings in a configuration file onto cfg. Unknown keys are
+// rejected so that typos do not silently fall back to the defaults.
+func (cfg *Config) applyFile(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	var file configFile
+	meta, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("unknown key(s) in config file %s: %s", path, strings.Join(keys, ", "))
+	}
+
+	dirs := file.System
+	if cfg.Scope == ScopeUser {
+		dirs = file.User
+	}
+	for _, setting := range []struct {
+		target *string
+		value  string
+	}{
+		{&cfg.StoreDir, dirs.StoreDir},
+		{&cfg.BinDir, dirs.BinDir},
+		{&cfg.DesktopDir, dirs.DesktopDir},
+		{&cfg.CacheDir, dirs.CacheDir},
+	} {
+		if setting.value != "" {
+			*setting.target = expandHome(setting.value)
+		}
+	}
+
+	if file.DefaultIcon != nil {
+		cfg.DefaultIcon = expandHome(*file.DefaultIcon)
+	}
+	if file.RefreshHooks != nil {
+		cfg.RefreshHooks = *file.RefreshHooks
+	}
+	if file.NonInteractive != nil {
+		cfg.NonInteractive = *file.NonInteractive
+	}
+	if file.AssumeYes != nil {
+		cfg.AssumeYes = *file.AssumeYes
+	}
+	if file.SignaturePolicy != nil {
+		cfg.SignaturePolicy = *file.SignaturePolicy
+	}
+	if file.SourceDirs != nil {
+		cfg.SourceDirs = make([]string, len(*file.SourceDirs))
+		for i, dir := range *file.SourceDirs {
+			cfg.SourceDirs[i] = expandHome(dir)
+		}
+	}
+	return nil
+}
+
+// applyEnv overlays the PACKAGEMANAGER_* environment variables onto cfg.
+func (cfg *Config) applyEnv() error {
+	for _, setting := range []struct {
+		name   string
+		target *string
+	}{
+		{"PACKAGEMANAGER_STORE_DIR", &cfg.StoreDir},
+		{"PACKAGEMANAGER_BIN_DIR", &cfg.BinDir},
+		{"PACKAGEMANAGER_DESKTOP_DIR", &cfg.DesktopDir},
+		{"PACKAGEMANAGER_CACHE_DIR", &cfg.CacheDir},
+		{"PACKAGEMANAGER_DEFAULT_ICON", &cfg.DefaultIcon},
+	} {
+		if value := os.Getenv(setting.name); value != "" {
+			*setting.target = expandHome(value)
+		}
+	}
+
+	for _, setting := range []struct {
+		name   string
+		target *bool
+	}{
+		{"PACKAGEMANAGER_NON_INTERACTIVE", &cfg.NonInteractive},
+		{"PACKAGEMANAGER_ASSUME_YES", &cfg.AssumeYes},
+	} {
+		if value := os.Getenv(setting.name); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", setting.name, value, err)
+			}
+			*setting.target = enabled
+		}
+	}
+	if value := os.Getenv("PACKAGEMANAGER_SIGNATURE_POLICY"); value != "" {
+		cfg.SignaturePolicy = value
+	}
+	if value := os.Getenv("PACKAGEMANAGER_SOURCE_DIRS"); value != "" {
+		cfg.SourceDirs = nil
+		for _, dir := range filepath.SplitList(value) {
+			cfg.SourceDirs = append(cfg.SourceDirs, expandHome(dir))
+		}
+	}
+	return nil
+}
+
+// Validate checks that every configured directory, including the source directories, is
+// an absolute path and that the signature policy is known.
+//
+// Returns:
+//   - error: An error object naming the first invalid setting, otherwise nil.
+func (cfg *Config) Validate() error {
+	for _, setting := range []struct {
+		name  string
+		value string
+	}{
+		{"store_dir", cfg.StoreDir},
+		{"bin_dir", cfg.BinDir},
+		{"desktop_dir", cfg.DesktopDir},
+		{"cache_dir", cfg.CacheDir},
+	} {
+		if !filepath.IsAbs(setting.value) {
+			return fmt.Errorf("%s must be an absolute path, got %q", setting.name, setting.value)
+		}
+	}
+	for _, dir := range cfg.SourceDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("source_dirs must contain absolute paths, got %q", dir)
+		}
+	}
+	if cfg.SignaturePolicy != SignaturesOptional && cfg.SignaturePolicy != SignaturesRequired {
+		return fmt.Errorf("signature_policy must be %q or %q, got %q", SignaturesOptional, SignaturesRequired, cfg.SignaturePolicy)
+	}
+	return nil
+}
+
+// DatabasePath returns the path of the scope's package database.
+//
+// Returns:
+//   - string: The path to packages.json within the store directory.
+func (cfg *Config) DatabasePath() string {
+	return filepath.Join(cfg.StoreDir, "packages.json")
+}
+
+// KeyringPath returns the path of the scope's keyring of trusted publisher keys.
+//
+// Returns:
+//   - string: The path to keyring.json within the store directory.
+func (cfg *Config) KeyringPath() string {
+	return filepath.Join(cfg.StoreDir, "keyring.json")
+}
+
+// RepositoriesPath returns the path of the scope's list of package repositories.
+//
+// Returns:
+//   - string: The path to repositories.json within the store directory.
+func (cfg *Config) RepositoriesPath() string {
+	return filepath.Join(cfg.StoreDir, "repositories.json")
+}
+
+// EnsureDirs creates the store, bin and desktop directories if they do not exist yet,
+// which is common for a fresh per-user installation.
+//
+// Returns:
+//   - error: An error object if a directory cannot be created, otherwise nil.
+func (cfg *Config) EnsureDirs() error {
+	for _, dir := range []string{cfg.StoreDir, cfg.BinDir, cfg.DesktopDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating directory %s: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// xdgDir returns the directory named by an XDG environment variable, or fallback when
+// it is unset or not absolute, as the specification requires.
+func xdgDir(name, fallback string) string {
+	if dir := os.Getenv(name); filepath.IsAbs(dir) {
+		return dir
+	}
+	return fallback
+}
+
+// expandHome replaces a leading "~/" with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
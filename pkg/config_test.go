@@ -0,0 +1,46 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyEnvBooleans(t *testing.T) {
+	t.Setenv("PACKAGEMANAGER_NON_INTERACTIVE", "true")
+	t.Setenv("PACKAGEMANAGER_ASSUME_YES", "1")
+	cfg := &Config{}
+	if err := cfg.applyEnv(); err != nil {
+		t.Fatalf("applyEnv: %v", err)
+	}
+	if !cfg.NonInteractive || !cfg.AssumeYes {
+		t.Errorf("NonInteractive = %v, AssumeYes = %v, want both true", cfg.NonInteractive, cfg.AssumeYes)
+	}
+
+	t.Setenv("PACKAGEMANAGER_ASSUME_YES", "maybe")
+	if err := cfg.applyEnv(); err == nil {
+		t.Errorf("applyEnv accepted PACKAGEMANAGER_ASSUME_YES=maybe")
+	}
+}
+
+func TestLoadConfigPlacesSourceDirsUnderRoot(t *testing.T) {
+	t.Setenv("PACKAGEMANAGER_CONFIG", "")
+	t.Setenv("PACKAGEMANAGER_SOURCE_DIRS", "")
+	root := t.TempDir()
+	configPath := filepath.Join(root, SystemConfigPath)
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(`source_dirs = ["/srv/archives"]`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(ScopeSystem, ConfigOverrides{Root: root})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := filepath.Join(root, "srv", "archives")
+	if len(cfg.SourceDirs) != 1 || cfg.SourceDirs[0] != want {
+		t.Errorf("SourceDirs = %v, want [%s]", cfg.SourceDirs, want)
+	}
+}
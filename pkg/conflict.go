@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Kinds of conflict reported by FindConflicts.
+const (
+	ConflictName      = "name"       // Another installed package has the same name, ignoring case.
+	ConflictBinLink   = "bin link"   // The symlink for one of the package's executables already exists.
+	ConflictDesktopID = "desktop ID" // The package's .desktop file already exists.
+)
+
+// Conflict is a collision between a package about to be installed and what is already
+// on the system.
+type Conflict struct {
+	Kind  string // One of the Conflict* constants.
+	Path  string // The contested path; empty for name conflicts.
+	Name  string // The contested package name, for name conflicts.
+	Owner string // The name of the installed package that owns the path or name, or "" if it is not managed.
+}
+
+// String describes the conflict.
+func (c Conflict) String() string {
+	if c.Kind == ConflictName {
+		return fmt.Sprintf("name %s: package %s is already installed", c.Name, c.Owner)
+	}
+	if c.Owner == "" {
+		return fmt.Sprintf("%s %s: exists and is not managed by packagemanager", c.Kind, c.Path)
+	}
+	return fmt.Sprintf("%s %s: owned by package %s", c.Kind, c.Path, c.Owner)
+}
+
+// FindConflicts checks whether installing a package would collide with another installed
+// package or with files not managed by the package manager. Paths owned by an installed
+// package of the same name are not conflicts, since installing replaces that package's
+// current generation.
+//
+// Parameters:
+//   - pm (*PackageManager): The package database.
+//   - cfg (*Config): The configuration giving the bin and desktop directories.
+//   - candidate (Package): The record of the package about to be installed.
+//
+// Returns:
+//   - []Conflict: Every conflict found, or nil if the package can be installed cleanly.
+func FindConflicts(pm *PackageManager, cfg *Config, candidate Package) []Conflict {
+	var conflicts []Conflict
+
+	// Desktop IDs are derived from the lowercased name, so names that differ only in case
+	// are ambiguous.
+	for _, p := range pm.Packages {
+		if p.Name != candidate.Name && strings.EqualFold(p.Name, candidate.Name) {
+			conflicts = append(conflicts, Conflict{Kind: ConflictName, Name: candidate.Name, Owner: p.Name})
+		}
+	}
+
+	contested := func(kind, path string) {
+		if _, err := os.Lstat(path); err != nil {
+			return
+		}
+		if owner := pm.OwnerOf(cfg, path); owner != candidate.Name {
+			conflicts = append(conflicts, Conflict{Kind: kind, Path: path, Owner: owner})
+		}
+	}
+	for _, executable := range candidate.LinkedExecutables() {
+		contested(ConflictBinLink, candidate.SymlinkPath(cfg, executable))
+	}
+	if !candidate.NoDesktop {
+		contested(ConflictDesktopID, DesktopFilePath(cfg, candidate.Name))
+	}
+
+	return conflicts
+}
+
+// OwnerOf finds the installed package that owns a symlink or .desktop file outside the
+// store, checking that the file still belongs to it.
+//
+// Parameters:
+//   - cfg (*Config): The configuration giving the bin and desktop directories.
+//   - path (string): The path of the file.
+//
+// Returns:
+//   - string: The name of the owning package, or "" if no installed package owns the file.
+func (pm *PackageManager) OwnerOf(cfg *Config, path string) string {
+	for _, p := range pm.Packages {
+		for _, owned := range p.ExternalFiles(cfg) {
+			if owned.Path == path && p.CheckOwnership(cfg, owned) == nil {
+				return p.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BackupPath returns the path of the rolling backup kept beside a packages file. The
+// backup always holds the last database that was successfully replaced by Save.
+//
+// Parameters:
+//   - packagesFile (string): The path to the packages file.
+//
+// Returns:
+//   - string: The path to the backup file.
+func BackupPath(packagesFile string) string {
+	return packagesFile + ".bak"
+}
+
+// loadDatabase reads the packages file. If it cannot be read or parsed, for example
+// because an older version was interrupted while writing it, the database is recovered
+// from the backup. When restore is set, which requires an exclusive lock, the damaged
+// file is kept aside for inspection and the backup is restored in its place; otherwise
+// the backup's contents are returned and no files are changed.
+func loadDatabase(packagesFile string, restore bool) ([]Package, error) {
+	packages, err := readPackagesFile(packagesFile)
+	if err == nil {
+		return packages, nil
+	}
+
+	backupFile := BackupPath(packagesFile)
+	backupData, backupErr := os.ReadFile(backupFile)
+	if backupErr != nil {
+		return nil, err
+	}
+	packages, backupErr = parsePackages(backupData)
+	if backupErr != nil {
+		return nil, fmt.Errorf("%v (the backup %s is also unusable: %v)", err, backupFile, backupErr)
+	}
+
+	if !restore {
+		fmt.Printf("Warning: %v; reading the backup %s instead. The next command that changes packages will restore it.\n", err, backupFile)
+		return packages, nil
+	}
+
+	fmt.Printf("Warning: %v; recovering from the backup %s.\n", err, backupFile)
+	corruptFile := fmt.Sprintf("%s.corrupt-%s", packagesFile, time.Now().UTC().Format("20060102T150405Z"))
+	if renameErr := os.Rename(packagesFile, corruptFile); renameErr == nil {
+		fmt.Printf("The damaged database was moved to %s.\n", corruptFile)
+	}
+	if err := writeFileAtomic(packagesFile, backupData, 0644); err != nil {
+		return nil, fmt.Errorf("error restoring packages file from backup: %v", err)
+	}
+	return packages, nil
+}
+
+// readPackagesFile reads and parses a packages file.
+func readPackagesFile(packagesFile string) ([]Package, error) {
+	data, err := os.ReadFile(packagesFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading packages file: %v", err)
+	}
+	packages, err := parsePackages(data)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling packages file %s: %v", packagesFile, err)
+	}
+	return packages, nil
+}
+
+// parsePackages decodes the JSON array stored in a packages file.
+func parsePackages(data []byte) ([]Package, error) {
+	packages := []Package{}
+	if err := json.Unmarshal(data, &packages); err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
+
+// writeDatabase replaces the packages file with data, first copying the current file
+// to the backup if it is a valid database. Valid JSON that is not a list of packages is
+// not backed up, so it can never replace a good backup. Both files are replaced atomically,
+// so after a crash at any point at least one of them holds a complete database.
+func writeDatabase(packagesFile string, data []byte) error {
+	if current, err := os.ReadFile(packagesFile); err == nil {
+		if _, err := parsePackages(current); err == nil {
+			if err := writeFileAtomic(BackupPath(packagesFile), current, 0644); err != nil {
+				return fmt.Errorf("error backing up packages file: %v", err)
+			}
+		}
+	}
+	return writeFileAtomic(packagesFile, data, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file beside path, flushes it to disk and
+// renames it over path, so that readers see either the old or the new contents but
+// never a partial write. The directory is synced so that the rename itself is durable.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tempFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+
+	// cleanup removes the temporary file if any step before the rename fails.
+	cleanup := func(err error) error {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return err
+	}
+
+	if _, err := tempFile.Write(data); err != nil {
+		return cleanup(err)
+	}
+	if err := tempFile.Chmod(perm); err != nil {
+		return cleanup(err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		return cleanup(err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return cleanup(err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes a directory's entries to disk. Errors are ignored: some platforms
+// cannot sync directories, and the rename has already happened.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDamagedDatabase writes a truncated packages file beside a valid backup.
+func writeDamagedDatabase(t *testing.T, dir string) string {
+	t.Helper()
+	packagesFile := filepath.Join(dir, "packages.json")
+	if err := os.WriteFile(packagesFile, []byte(`[{"uuid": "1", "na`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(BackupPath(packagesFile), []byte(`[{"uuid": "1", "name": "tool"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return packagesFile
+}
+
+func TestSharedLockReadsBackupWithoutChangingFiles(t *testing.T) {
+	dir := t.TempDir()
+	packagesFile := writeDamagedDatabase(t, dir)
+	before := listTree(t, dir)
+	damaged, _ := os.ReadFile(packagesFile)
+
+	pm, err := NewPackageManagerWithLock(packagesFile, "", SharedLock)
+	if err != nil {
+		t.Fatalf("NewPackageManagerWithLock: %v", err)
+	}
+	if len(pm.Packages) != 1 || pm.Packages[0].Name != "tool" {
+		t.Errorf("read %+v, want the backup's package", pm.Packages)
+	}
+
+	after := listTree(t, dir)
+	if len(after) != len(before) {
+		t.Errorf("files changed under a shared lock: %v, was %v", after, before)
+	}
+	if data, _ := os.ReadFile(packagesFile); string(data) != string(damaged) {
+		t.Errorf("packages file was rewritten under a shared lock: %s", data)
+	}
+}
+
+func TestExclusiveLockRestoresBackup(t *testing.T) {
+	dir := t.TempDir()
+	packagesFile := writeDamagedDatabase(t, dir)
+
+	pm, err := NewPackageManagerWithLock(packagesFile, "", ExclusiveLock)
+	if err != nil {
+		t.Fatalf("NewPackageManagerWithLock: %v", err)
+	}
+	if len(pm.Packages) != 1 || pm.Packages[0].Name != "tool" {
+		t.Errorf("read %+v, want the backup's package", pm.Packages)
+	}
+	if _, err := readPackagesFile(packagesFile); err != nil {
+		t.Errorf("packages file was not restored: %v", err)
+	}
+	if corrupt, _ := filepath.Glob(packagesFile + ".corrupt-*"); len(corrupt) != 1 {
+		t.Errorf("damaged file kept as %v, want one copy", corrupt)
+	}
+}
+
+func TestSharedLockDoesNotCreateDatabase(t *testing.T) {
+	packagesFile := filepath.Join(t.TempDir(), "store", "packages.json")
+
+	pm, err := NewPackageManagerWithLock(packagesFile, "", SharedLock)
+	if err != nil {
+		t.Fatalf("NewPackageManagerWithLock: %v", err)
+	}
+	if len(pm.Packages) != 0 {
+		t.Errorf("read %+v from a missing database", pm.Packages)
+	}
+	if _, err := os.Stat(filepath.Dir(packagesFile)); !os.IsNotExist(err) {
+		t.Errorf("store directory created under a shared lock")
+	}
+}
+
+func TestWriteDatabaseBacksUpOnlyPackageLists(t *testing.T) {
+	dir := t.TempDir()
+	packagesFile := filepath.Join(dir, "packages.json")
+	backup := `[{"uuid": "1", "name": "tool"}]`
+	if err := os.WriteFile(BackupPath(packagesFile), []byte(backup), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Valid JSON that is not a list of packages must not replace the good backup.
+	for _, current := range []string{`{"name": "tool"}`, `"packages"`, `[1, 2]`} {
+		if err := os.WriteFile(packagesFile, []byte(current), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeDatabase(packagesFile, []byte(`[]`)); err != nil {
+			t.Fatalf("writeDatabase: %v", err)
+		}
+		if data, _ := os.ReadFile(BackupPath(packagesFile)); string(data) != backup {
+			t.Errorf("backup replaced by %s: %s", current, data)
+		}
+	}
+
+	// A list of packages is backed up before it is replaced.
+	current := `[{"uuid": "2", "name": "other"}]`
+	if err := os.WriteFile(packagesFile, []byte(current), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDatabase(packagesFile, []byte(`[]`)); err != nil {
+		t.Fatalf("writeDatabase: %v", err)
+	}
+	if data, _ := os.ReadFile(BackupPath(packagesFile)); string(data) != current {
+		t.Errorf("backup is %s, want %s", data, current)
+	}
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Decompressor describes a compression format that can wrap a tar stream.
+// Decompressors are identified by the magic bytes at the start of the stream,
+// so archives are handled correctly regardless of their file extension.
+type Decompressor struct {
+	Name      string                                   // A short name for the compression format, e.g. "gzip".
+	Extension string                                   // The conventional tarball suffix, e.g. ".tar.gz".
+	Magic     []byte                                   // The leading bytes that identify a stream in this format.
+	NewReader func(r io.Reader) (io.ReadCloser, error) // Wraps a compressed stream in a decompressing reader.
+}
+
+// decompressors holds the registered compression formats, checked in order.
+var decompressors = []Decompressor{
+	{
+		Name:      "gzip",
+		Extension: ".tar.gz",
+		Magic:     []byte{0x1f, 0x8b},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		Name:      "bzip2",
+		Extension: ".tar.bz2",
+		Magic:     []byte{'B', 'Z', 'h'},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		Name:      "xz",
+		Extension: ".tar.xz",
+		Magic:     []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			xzReader, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(xzReader), nil
+		},
+	},
+	{
+		Name:      "zstd",
+		Extension: ".tar.zst",
+		Magic:     []byte{0x28, 0xb5, 0x2f, 0xfd},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			zstdReader, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zstdReader.IOReadCloser(), nil
+		},
+	},
+}
+
+// RegisterDecompressor adds a compression format to the set recognised by the tar extractor.
+// Formats registered later take precedence over the built-in ones when their magic bytes overlap.
+//
+// Parameters:
+//   - d (Decompressor): The compression format to register.
+func RegisterDecompressor(d Decompressor) {
+	decompressors = append([]Decompressor{d}, decompressors...)
+}
+
+// detectDecompressor returns the decompressor whose magic bytes prefix the given header,
+// or nil if the header does not match any registered compression format.
+func detectDecompressor(header []byte) *Decompressor {
+	for i := range decompressors {
+		if bytes.HasPrefix(header, decompressors[i].Magic) {
+			return &decompressors[i]
+		}
+	}
+	return nil
+}
+
+// maxMagicLength returns the length of the longest registered magic byte sequence.
+func maxMagicLength() int {
+	n := 0
+	for _, d := range decompressors {
+		if len(d.Magic) > n {
+			n = len(d.Magic)
+		}
+	}
+	return n
+}
+
+// NewDecompressingReader sniffs the compression format of r and returns a reader
+// yielding the decompressed stream. Uncompressed input is passed through unchanged.
+//
+// Parameters:
+//   - r (io.Reader): The possibly compressed input stream.
+//
+// Returns:
+//   - io.ReadCloser: A reader over the decompressed data.
+//   - string: The name of the detected compression format, or "none".
+//   - error: An error object if the decompressor cannot be initialised, otherwise nil.
+func NewDecompressingReader(r io.Reader) (io.ReadCloser, string, error) {
+	buffered := bufio.NewReader(r)
+
+	// Peek at the header without consuming it so the decompressor sees the full stream.
+	header, err := buffered.Peek(maxMagicLength())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", fmt.Errorf("error reading compression header: %v", err)
+	}
+
+	d := detectDecompressor(header)
+	if d == nil {
+		return io.NopCloser(buffered), "none", nil
+	}
+
+	reader, err := d.NewReader(buffered)
+	if err != nil {
+		return nil, d.Name, fmt.Errorf("error creating %s reader: %v", d.Name, err)
+	}
+	return reader, d.Name, nil
+}
+
+// TarballExtensions returns the filename suffixes conventionally used for tarballs
+// in every registered compression format, including common short forms.
+func TarballExtensions() []string {
+	extensions := []string{}
+	for _, d := range decompressors {
+		if d.Extension != "" {
+			extensions = append(extensions, d.Extension)
+		}
+	}
+	return append(extensions, ".tgz", ".tbz2", ".tbz", ".txz", ".tzst", ".tar")
+}
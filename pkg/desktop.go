@@ -8,40 +8,95 @@ import (
 	"strings"
 )
 
+// DesktopOptions carries optional metadata for CreateDesktopFile, typically taken from
+// a package manifest. A nil *DesktopOptions produces the same minimal entry as before.
+type DesktopOptions struct {
+	Icon       string       // An absolute icon path; when empty, the install directory is searched for one.
+	Comment    string       // A tooltip for the menu entry, usually the package description.
+	Categories []string     // Menu categories; defaults to "Utility".
+	Entry      DesktopEntry // Further .desktop keys declared by the package.
+}
+
+// DesktopFilePath returns the path of the .desktop file for a package, named after
+// the package in lowercase.
+func DesktopFilePath(cfg *Config, packageName string) string {
+	return filepath.Join(cfg.DesktopDir, fmt.Sprintf("%s.desktop", strings.ToLower(packageName)))
+}
+
 // CreateDesktopFile generates a .desktop file for the given executable.
 // The .desktop file is used to integrate the application with desktop environments,
 // allowing it to appear in application menus and support desktop shortcuts.
-func CreateDesktopFile(executablePath, packageName, installPath string) error {
-	// Define the directory where .desktop files are stored.
-	desktopDir := "/usr/share/applications"
+// The file is written to the configured desktop directory.
+func CreateDesktopFile(cfg *Config, executablePath, packageName, installPath string, opts *DesktopOptions) error {
+	if opts == nil {
+		opts = &DesktopOptions{}
+	}
 
 	// Construct the full path to the .desktop file, using the package name in lowercase.
-	desktopFilePath := filepath.Join(desktopDir, fmt.Sprintf("%s.desktop", strings.ToLower(packageName)))
+	desktopFilePath := DesktopFilePath(cfg, packageName)
+
+	// Retrieve the path to the application's icon, preferring one declared by the package.
+	iconPath := opts.Icon
+	if iconPath == "" {
+		iconPath = getDefaultIcon(installPath, cfg.DefaultIcon)
+	}
+
+	categories := opts.Categories
+	if len(categories) == 0 {
+		categories = []string{"Utility"}
+	}
+
+	// Paths in the entry must be valid inside an alternate root once it is booted.
+	iconPath = cfg.ImagePath(iconPath)
+	exec := cfg.ImagePath(executablePath)
+	if opts.Entry.Args != "" {
+		exec = fmt.Sprintf("%s %s", exec, opts.Entry.Args)
+	}
 
-	// Retrieve the path to the application's icon.
-	iconPath := getDefaultIcon(installPath)
+	comment := opts.Entry.Comment
+	if comment == "" {
+		comment = opts.Comment
+	}
 
 	// Define the content of the .desktop file following the Desktop Entry Specification.
-	desktopContent := fmt.Sprintf(`[Desktop Entry]
-Type=Application
-Name=%s
-Exec=%s
-Icon=%s
-Terminal=false
-Categories=Utility;`, packageName, executablePath, iconPath)
-
-	// Create or overwrite the .desktop file with the defined content.
-	file, err := os.Create(desktopFilePath)
-	if err != nil {
-		return fmt.Errorf("error creating .desktop file: %v", err)
+	var b strings.Builder
+	b.WriteString("[Desktop Entry]\n")
+	b.WriteString("Type=Application\n")
+	fmt.Fprintf(&b, "Name=%s\n", packageName)
+	if opts.Entry.GenericName != "" {
+		fmt.Fprintf(&b, "GenericName=%s\n", opts.Entry.GenericName)
+	}
+	if comment != "" {
+		fmt.Fprintf(&b, "Comment=%s\n", comment)
+	}
+	fmt.Fprintf(&b, "Exec=%s\n", exec)
+	fmt.Fprintf(&b, "Icon=%s\n", iconPath)
+	fmt.Fprintf(&b, "Terminal=%t\n", opts.Entry.Terminal)
+	if opts.Entry.NoDisplay {
+		b.WriteString("NoDisplay=true\n")
+	}
+	if opts.Entry.StartupWMClass != "" {
+		fmt.Fprintf(&b, "StartupWMClass=%s\n", opts.Entry.StartupWMClass)
 	}
-	defer file.Close()
+	if len(opts.Entry.MimeTypes) > 0 {
+		fmt.Fprintf(&b, "MimeType=%s;\n", strings.Join(opts.Entry.MimeTypes, ";"))
+	}
+	if len(opts.Entry.Keywords) > 0 {
+		fmt.Fprintf(&b, "Keywords=%s;\n", strings.Join(opts.Entry.Keywords, ";"))
+	}
+	fmt.Fprintf(&b, "Categories=%s;", strings.Join(categories, ";"))
+	desktopContent := b.String()
 
-	// Write the desktop content to the file.
-	_, err = file.WriteString(desktopContent)
-	if err != nil {
+	// Write the new content beside the .desktop file and rename it into place, so that
+	// desktop environments never observe a half-written entry when it is replaced.
+	tempPath := desktopFilePath + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(desktopContent), 0644); err != nil {
 		return fmt.Errorf("error writing to .desktop file: %v", err)
 	}
+	if err := os.Rename(tempPath, desktopFilePath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("error creating .desktop file: %v", err)
+	}
 
 	// Inform the user that the .desktop file has been created successfully.
 	fmt.Printf("Created .desktop file at %s\n", desktopFilePath)
@@ -50,33 +105,43 @@ Categories=Utility;`, packageName, executablePath, iconPath)
 
 // RemoveDesktopFile deletes the .desktop file associated with the specified package.
 // This function ensures that the application is removed from desktop environment menus.
-func RemoveDesktopFile(packageName string) error {
-	// Define the directory where .desktop files are stored.
-	desktopDir := "/usr/share/applications"
-
+// The file is only deleted if the package created it and it still belongs to the package;
+// an entry written by something else under the same name is left alone.
+func RemoveDesktopFile(cfg *Config, p Package) error {
 	// Construct the full path to the .desktop file, using the package name in lowercase.
-	desktopFilePath := filepath.Join(desktopDir, fmt.Sprintf("%s.desktop", strings.ToLower(packageName)))
+	desktopFilePath := DesktopFilePath(cfg, p.Name)
 
-	// Check if the .desktop file exists.
-	if _, err := os.Stat(desktopFilePath); os.IsNotExist(err) {
-		// If the .desktop file does not exist, there's nothing to remove.
-		return nil
-	}
+	for _, owned := range p.ExternalFiles(cfg) {
+		if owned.Path != desktopFilePath {
+			continue
+		}
 
-	// Attempt to remove the .desktop file.
-	err := os.Remove(desktopFilePath)
-	if err != nil {
-		return fmt.Errorf("error removing .desktop file: %v", err)
+		// Check that the .desktop file exists and still belongs to the package.
+		if err := p.CheckOwnership(cfg, owned); err != nil {
+			if os.IsNotExist(err) {
+				// If the .desktop file does not exist, there's nothing to remove.
+				return nil
+			}
+			return err
+		}
+
+		// Attempt to remove the .desktop file.
+		if err := os.Remove(desktopFilePath); err != nil {
+			return fmt.Errorf("error removing .desktop file: %v", err)
+		}
+
+		// Inform the user that the .desktop file has been removed successfully.
+		fmt.Printf("Removed .desktop file at %s\n", desktopFilePath)
+		return nil
 	}
 
-	// Inform the user that the .desktop file has been removed successfully.
-	fmt.Printf("Removed .desktop file at %s\n", desktopFilePath)
+	// The package never created a .desktop file, so there is nothing of its own to remove.
 	return nil
 }
 
 // getDefaultIcon searches for a default icon within the installation directory.
-// If no icon is found, it returns a predefined default icon path.
-func getDefaultIcon(installPath string) string {
+// If no icon is found, it returns defaultIcon, the configured fallback icon.
+func getDefaultIcon(installPath, defaultIcon string) string {
 	// Define possible icon file extensions to search for.
 	iconExtensions := []string{".png", ".jpg", ".jpeg", ".ico", ".svg"}
 
@@ -118,5 +183,5 @@ func getDefaultIcon(installPath string) string {
 	}
 
 	// If no icon was found, return the path to the default icon.
-	return "/usr/share/pixmaps/default-icon.png"
+	return defaultIcon
 }
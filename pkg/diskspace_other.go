@@ -0,0 +1,8 @@
+//go:build !unix
+
+package pkg
+
+// availableBytes is not implemented on this platform, so free-space checks are skipped.
+func availableBytes(path string) (int64, bool, error) {
+	return 0, false, nil
+}
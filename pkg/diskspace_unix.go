@@ -0,0 +1,15 @@
+//go:build unix
+
+package pkg
+
+import "syscall"
+
+// availableBytes reports the space available to unprivileged users on the filesystem
+// containing path. The boolean result indicates whether the figure is meaningful.
+func availableBytes(path string) (int64, bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false, err
+	}
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), true, nil
+}
@@ -0,0 +1,216 @@
+package pkg
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Kinds of issue reported by Diagnose.
+const (
+	IssueOrphanedDirectory   = "orphaned install directory" // A store directory that no package record refers to.
+	IssueMissingInstall      = "missing install directory"  // A package whose current installation directory is gone.
+	IssueMissingGeneration   = "missing generation"         // A retained generation whose installation directory is gone.
+	IssueDanglingSymlink     = "dangling symlink"           // A bin directory symlink into the store whose target is gone.
+	IssueMissingSymlink      = "missing symlink"            // A package executable with no symlink in the bin directory.
+	IssueStaleDesktopEntry   = "stale desktop entry"        // A .desktop file launching a missing executable from the store.
+	IssueMissingDesktopEntry = "missing desktop entry"      // A package installed with a .desktop file that no longer exists.
+	IssueLeftoverFile        = "leftover temporary file"    // A file left behind by an interrupted write or transaction.
+)
+
+// Issue is a divergence between the package database and the filesystem.
+type Issue struct {
+	Kind    string // One of the Issue* constants.
+	Path    string // The path the issue concerns.
+	Package string // The name of the package involved, if any.
+	Detail  string // A human-readable explanation.
+}
+
+// Diagnose scans the store, bin and desktop directories and the package database for
+// state that has diverged: store directories without a record, records whose files are
+// gone, dangling or missing symlinks, stale or missing .desktop entries, and files left
+// behind by interrupted operations.
+//
+// Parameters:
+//   - pm (*PackageManager): The package database.
+//   - cfg (*Config): The configuration giving the store, bin and desktop directories.
+//
+// Returns:
+//   - []Issue: The issues found, problems with package records first.
+//   - error: An error object if a directory cannot be read, otherwise nil.
+func Diagnose(pm *PackageManager, cfg *Config) ([]Issue, error) {
+	var issues []Issue
+
+	// Paths that belong to a package record, so that anything else in the store is an orphan.
+	known := map[string]bool{}
+	for _, p := range pm.Packages {
+		for _, installPath := range p.InstallPaths() {
+			known[installPath] = true
+		}
+	}
+
+	// Records whose installation directories are gone.
+	for _, p := range pm.Packages {
+		if !exists(p.InstallPath) {
+			issues = append(issues, Issue{Kind: IssueMissingInstall, Path: p.InstallPath, Package: p.Name, Detail: "the current installation directory no longer exists"})
+			continue
+		}
+		for _, generation := range p.Generations {
+			if !exists(generation.InstallPath) {
+				issues = append(issues, Issue{Kind: IssueMissingGeneration, Path: generation.InstallPath, Package: p.Name, Detail: "a retained version no longer exists"})
+			}
+		}
+
+		// Integration files that should exist for an intact installation.
+		for _, executable := range p.LinkedExecutables() {
+			symlinkPath := p.SymlinkPath(cfg, executable)
+			if _, err := os.Lstat(symlinkPath); os.IsNotExist(err) && exists(executable) {
+				issues = append(issues, Issue{Kind: IssueMissingSymlink, Path: symlinkPath, Package: p.Name, Detail: "should point to " + cfg.ImagePath(executable)})
+			}
+		}
+		if !p.NoDesktop && exists(p.Executable) {
+			desktopPath := DesktopFilePath(cfg, p.Name)
+			if _, err := os.Lstat(desktopPath); os.IsNotExist(err) {
+				issues = append(issues, Issue{Kind: IssueMissingDesktopEntry, Path: desktopPath, Package: p.Name, Detail: "the package was installed with a .desktop file"})
+			}
+		}
+	}
+
+	// Store directories without a record, and leftovers from interrupted operations.
+	entries, err := readDirIfExists(cfg.StoreDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(cfg.StoreDir, entry.Name())
+		switch {
+		case isLeftover(entry.Name()):
+			issues = append(issues, Issue{Kind: IssueLeftoverFile, Path: path, Detail: "left behind by an interrupted operation"})
+		case entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && !known[path]:
+			issues = append(issues, Issue{Kind: IssueOrphanedDirectory, Path: path, Detail: "no package record refers to it"})
+		}
+	}
+
+	// The store as seen by symlinks and .desktop files, which use paths inside the root.
+	imageStore := cfg.ImagePath(cfg.StoreDir)
+
+	entries, err = readDirIfExists(cfg.BinDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(cfg.BinDir, entry.Name())
+		if isLeftover(entry.Name()) {
+			issues = append(issues, Issue{Kind: IssueLeftoverFile, Path: path, Detail: "left behind by an interrupted operation"})
+			continue
+		}
+		target, err := os.Readlink(path)
+		if err != nil || !filepath.IsAbs(target) || !isWithin(imageStore, target) {
+			continue
+		}
+		if !exists(cfg.HostPath(target)) {
+			issues = append(issues, Issue{Kind: IssueDanglingSymlink, Path: path, Package: linkOwner(pm, cfg, path), Detail: "points to missing " + target})
+		}
+	}
+
+	entries, err = readDirIfExists(cfg.DesktopDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(cfg.DesktopDir, entry.Name())
+		if isLeftover(entry.Name()) {
+			issues = append(issues, Issue{Kind: IssueLeftoverFile, Path: path, Detail: "left behind by an interrupted operation"})
+			continue
+		}
+		if filepath.Ext(entry.Name()) != ".desktop" {
+			continue
+		}
+		executable := DesktopExecutable(path)
+		if executable == "" || !isWithin(imageStore, executable) {
+			continue
+		}
+		if !exists(cfg.HostPath(executable)) {
+			issues = append(issues, Issue{Kind: IssueStaleDesktopEntry, Path: path, Package: desktopOwner(pm, cfg, path), Detail: "launches missing " + executable})
+		}
+	}
+
+	return issues, nil
+}
+
+// linkOwner returns the name of the installed package that links an executable at
+// symlinkPath, or "" if none does.
+func linkOwner(pm *PackageManager, cfg *Config, symlinkPath string) string {
+	for _, p := range pm.Packages {
+		for _, executable := range p.LinkedExecutables() {
+			if p.SymlinkPath(cfg, executable) == symlinkPath {
+				return p.Name
+			}
+		}
+	}
+	return ""
+}
+
+// desktopOwner returns the name of the installed package whose .desktop file is at
+// desktopPath, or "" if none is.
+func desktopOwner(pm *PackageManager, cfg *Config, desktopPath string) string {
+	for _, p := range pm.Packages {
+		if !p.NoDesktop && DesktopFilePath(cfg, p.Name) == desktopPath {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// DesktopExecutable returns the program launched by a .desktop file's Exec key.
+//
+// Parameters:
+//   - desktopPath (string): The path of the .desktop file.
+//
+// Returns:
+//   - string: The program path, or "" if the file cannot be read or has no Exec key.
+func DesktopExecutable(desktopPath string) string {
+	file, err := os.Open(desktopPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "Exec="); ok {
+			if fields := strings.Fields(value); len(fields) > 0 {
+				return fields[0]
+			}
+		}
+	}
+	return ""
+}
+
+// leftoverPattern matches the temporary names this tool generates: ".<base>.tmp-<n>" for
+// symlinks and database writes replaced in place, ".<base>.removed-<pid>-<n>" for removals
+// staged by a transaction, and "<name>.desktop.tmp" for .desktop files being rewritten.
+var leftoverPattern = regexp.MustCompile(`^(\..+\.tmp-\d+|\..+\.removed-\d+-\d+|.+\.desktop\.tmp)$`)
+
+// isLeftover reports whether a file name is one of the temporary names used while
+// replacing files or staging removals.
+func isLeftover(name string) bool {
+	return leftoverPattern.MatchString(name)
+}
+
+// readDirIfExists lists a directory, treating a missing directory as empty.
+func readDirIfExists(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+// exists reports whether a path exists, following symlinks.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
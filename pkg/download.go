@@ -0,0 +1,364 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDownloadTimeout is how long a download may go without connecting, receiving
+// response headers or receiving data before it is abandoned.
+const DefaultDownloadTimeout = 30 * time.Second
+
+// DownloadOptions controls how Download fetches an archive.
+type DownloadOptions struct {
+	CacheDir string        // The directory where partial and completed downloads are kept.
+	Timeout  time.Duration // How long the connection may stall; zero uses DefaultDownloadTimeout.
+
+	// Progress, if set, is called as data arrives with the number of bytes downloaded so
+	// far and the total size, or -1 if the server did not say.
+	Progress func(done, total int64)
+}
+
+// downloadRecord is the cache metadata kept next to a download, so that an interrupted
+// download can be resumed and a completed one revalidated.
+type downloadRecord struct {
+	URL          string `json:"url"`
+	FileName     string `json:"file_name"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Complete     bool   `json:"complete"`
+}
+
+// IsURL reports whether an install source is an HTTP(S) URL rather than a local path.
+//
+// Parameters:
+//   - source (string): The archive path or URL given on the command line.
+//
+// Returns:
+//   - bool: True if source is an http:// or https:// URL.
+func IsURL(source string) bool {
+	lower := strings.ToLower(source)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Download fetches an archive over HTTP(S) into the download cache. An interrupted
+// download is resumed with a range request when the server supports it and the file
+// has not changed, and a completed download is reused if the server reports that it is
+// still current. The cached file is named after the Content-Disposition header or the
+// last element of the URL path, so the archive name and version can be derived from it.
+//
+// Parameters:
+//   - rawURL (string): The URL of the archive.
+//   - opts (DownloadOptions): The cache directory, timeout and progress callback.
+//
+// Returns:
+//   - string: The path of the downloaded archive in the cache.
+//   - error: An error object if the download fails or stalls, otherwise nil.
+func Download(rawURL string, opts DownloadOptions) (string, error) {
+	if _, err := url.Parse(rawURL); err != nil {
+		return "", fmt.Errorf("invalid URL %s: %v", rawURL, err)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultDownloadTimeout
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0755); err != nil {
+		return "", fmt.Errorf("error creating download cache %s: %v", opts.CacheDir, err)
+	}
+
+	sum := sha256.Sum256([]byte(rawURL))
+	key := hex.EncodeToString(sum[:8])
+	d := &download{
+		url:        rawURL,
+		opts:       opts,
+		recordPath: filepath.Join(opts.CacheDir, key+".json"),
+		partPath:   filepath.Join(opts.CacheDir, key+".part"),
+		finalDir:   filepath.Join(opts.CacheDir, key),
+	}
+
+	archivePath, err := d.fetch(true)
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s: %v", rawURL, err)
+	}
+	return archivePath, nil
+}
+
+// download is the state of a single Download call.
+type download struct {
+	url        string
+	opts       DownloadOptions
+	recordPath string // The cache metadata.
+	partPath   string // The partially downloaded file.
+	finalDir   string // The directory holding the completed file.
+}
+
+// fetch performs one request, resuming or revalidating from the cache. If the server
+// rejects a resume, the partial file is discarded and the download restarted once.
+func (d *download) fetch(allowRetry bool) (string, error) {
+	record := d.readRecord()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "packagemanager")
+
+	// Revalidate a completed download, or resume a partial one. Resuming is only safe
+	// with a validator that proves the file has not changed since.
+	var offset int64
+	completed := filepath.Join(d.finalDir, record.FileName)
+	if record.Complete && record.FileName != "" && exists(completed) {
+		if record.ETag != "" {
+			req.Header.Set("If-None-Match", record.ETag)
+		} else if record.LastModified != "" {
+			req.Header.Set("If-Modified-Since", record.LastModified)
+		}
+	} else if info, err := os.Stat(d.partPath); err == nil && info.Size() > 0 && (record.ETag != "" || record.LastModified != "") {
+		offset = info.Size()
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if record.ETag != "" {
+			req.Header.Set("If-Range", record.ETag)
+		} else {
+			req.Header.Set("If-Range", record.LastModified)
+		}
+	}
+
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if record.Complete && exists(completed) {
+			return completed, nil
+		}
+		return "", fmt.Errorf("server returned %s for a file that is not cached", resp.Status)
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return "", fmt.Errorf("server returned an unexpected range %q", resp.Header.Get("Content-Range"))
+		}
+	case http.StatusOK:
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		os.Remove(d.partPath)
+		if allowRetry {
+			return d.fetch(false)
+		}
+		return "", fmt.Errorf("server returned %s", resp.Status)
+	default:
+		return "", fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	// Remember how to resume before any data is written.
+	fileName := fileNameFromResponse(resp, d.url)
+	if resp.StatusCode == http.StatusPartialContent && record.FileName != "" {
+		fileName = record.FileName
+	}
+	record = downloadRecord{
+		URL:          d.url,
+		FileName:     fileName,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if err := d.writeRecord(record); err != nil {
+		return "", err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	part, err := os.OpenFile(d.partPath, flags, 0644)
+	if err != nil {
+		return "", err
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+
+	body := &stallReader{r: resp.Body, timeout: d.opts.Timeout, cancel: cancel}
+	body.start()
+	written, err := io.Copy(part, &progressReader{r: body, done: offset, total: total, progress: d.opts.Progress})
+	body.stop()
+	if closeErr := part.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if body.stalled() {
+			return "", fmt.Errorf("no data received for %v; run the command again to resume", d.opts.Timeout)
+		}
+		return "", fmt.Errorf("%v; run the command again to resume", err)
+	}
+	if total >= 0 && offset+written != total {
+		return "", fmt.Errorf("received %d of %d bytes; run the command again to resume", offset+written, total)
+	}
+
+	// Move the finished file into place under its own name.
+	if err := os.RemoveAll(d.finalDir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(d.finalDir, 0755); err != nil {
+		return "", err
+	}
+	archivePath := filepath.Join(d.finalDir, record.FileName)
+	if err := os.Rename(d.partPath, archivePath); err != nil {
+		return "", err
+	}
+	record.Complete = true
+	if err := d.writeRecord(record); err != nil {
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// client returns an HTTP client that gives up on connections and responses that take
+// longer than the timeout. Stalls while reading the body are handled by stallReader.
+func (d *download) client() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: d.opts.Timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = d.opts.Timeout
+	transport.ResponseHeaderTimeout = d.opts.Timeout
+	return &http.Client{Transport: transport}
+}
+
+// readRecord loads the cache metadata, ignoring it if it is missing, unreadable or
+// belongs to another URL.
+func (d *download) readRecord() downloadRecord {
+	var record downloadRecord
+	data, err := os.ReadFile(d.recordPath)
+	if err != nil || json.Unmarshal(data, &record) != nil || record.URL != d.url {
+		return downloadRecord{}
+	}
+	return record
+}
+
+// writeRecord saves the cache metadata.
+func (d *download) writeRecord(record downloadRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(d.recordPath, data, 0644)
+}
+
+// fileNameFromResponse picks the name of a downloaded file from the Content-Disposition
+// header, falling back to the last element of the URL path.
+func fileNameFromResponse(resp *http.Response, rawURL string) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := safeFileName(params["filename"]); name != "" {
+			return name
+		}
+	}
+
+	// Use the URL after redirects, since it usually names the actual file.
+	u := resp.Request.URL
+	if u == nil {
+		var err error
+		if u, err = url.Parse(rawURL); err != nil {
+			return "download"
+		}
+	}
+	if name := safeFileName(path.Base(u.Path)); name != "" {
+		return name
+	}
+	return "download"
+}
+
+// safeFileName reduces a server-supplied name to a single path element, returning "" if
+// nothing usable is left.
+func safeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == ".." || name == "/" || strings.HasPrefix(name, ".") {
+		return ""
+	}
+	return name
+}
+
+// contentRangeStart parses the first byte position of a "bytes start-end/size" header.
+func contentRangeStart(header string) (int64, bool) {
+	rangeSpec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(rangeSpec, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	return n, err == nil
+}
+
+// progressReader reports the running total of bytes read to a progress callback.
+type progressReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	progress func(done, total int64)
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.done += int64(n)
+	if pr.progress != nil && (n > 0 || errors.Is(err, io.EOF)) {
+		pr.progress(pr.done, pr.total)
+	}
+	return n, err
+}
+
+// stallReader cancels a request when no data arrives within the timeout.
+type stallReader struct {
+	r       io.Reader
+	timeout time.Duration
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	timedOut bool
+}
+
+func (sr *stallReader) start() {
+	sr.timer = time.AfterFunc(sr.timeout, func() {
+		sr.mu.Lock()
+		sr.timedOut = true
+		sr.mu.Unlock()
+		sr.cancel()
+	})
+}
+
+func (sr *stallReader) stop() {
+	sr.timer.Stop()
+}
+
+func (sr *stallReader) stalled() bool {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.timedOut
+}
+
+func (sr *stallReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	if n > 0 {
+		sr.timer.Reset(sr.timeout)
+	}
+	return n, err
+}
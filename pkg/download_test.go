@@ -0,0 +1,212 @@
+package pkg
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTimeout is the stall timeout used by downloads in tests.
+const testTimeout = 200 * time.Millisecond
+
+// archiveServer serves one file. Its first response can be cut short to leave a partial
+// download behind, and it can be told to ignore range requests.
+type archiveServer struct {
+	mu          sync.Mutex
+	content     []byte
+	etag        string
+	stallAfter  int  // If positive, the next response stalls after this many bytes.
+	ignoreRange bool // Whether to answer range requests with the whole file.
+	ranges      []string
+}
+
+func (s *archiveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, etag, stallAfter, ignoreRange := s.content, s.etag, s.stallAfter, s.ignoreRange
+	s.stallAfter = 0
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+	if stallAfter > 0 {
+		// Promise the whole file, send part of it, then go quiet until the client gives up.
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content[:stallAfter])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	}
+	if ignoreRange {
+		r.Header.Del("Range")
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+func (s *archiveServer) set(f func(s *archiveServer)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s)
+}
+
+func (s *archiveServer) lastRange() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ranges[len(s.ranges)-1]
+}
+
+// startArchiveServer serves s at /tool-1.0.tar.gz and returns the archive's URL.
+func startArchiveServer(t *testing.T, s *archiveServer) string {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server.URL + "/tool-1.0.tar.gz"
+}
+
+// interruptedDownload runs a download that stalls half way, leaving a partial file in cacheDir.
+func interruptedDownload(t *testing.T, s *archiveServer, rawURL, cacheDir string) {
+	t.Helper()
+	s.set(func(s *archiveServer) { s.stallAfter = len(s.content) / 2 })
+	if _, err := Download(rawURL, DownloadOptions{CacheDir: cacheDir, Timeout: testTimeout}); err == nil {
+		t.Fatalf("stalled download succeeded")
+	}
+}
+
+func assertDownloaded(t *testing.T, archivePath string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatalf("reading download: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("downloaded %q, want %q", got, want)
+	}
+}
+
+func TestDownloadResumesWithRange(t *testing.T) {
+	s := &archiveServer{content: []byte("0123456789abcdefghij"), etag: `"v1"`}
+	rawURL := startArchiveServer(t, s)
+	cacheDir := t.TempDir()
+
+	interruptedDownload(t, s, rawURL, cacheDir)
+	archivePath, err := Download(rawURL, DownloadOptions{CacheDir: cacheDir, Timeout: testTimeout})
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got := s.lastRange(); got != "bytes=10-" {
+		t.Errorf("resumed with Range %q, want %q", got, "bytes=10-")
+	}
+	if filepath.Base(archivePath) != "tool-1.0.tar.gz" {
+		t.Errorf("saved as %s, want tool-1.0.tar.gz", filepath.Base(archivePath))
+	}
+	assertDownloaded(t, archivePath, s.content)
+}
+
+func TestDownloadRestartsWhenRangeIgnored(t *testing.T) {
+	s := &archiveServer{content: []byte("0123456789abcdefghij"), etag: `"v1"`, ignoreRange: true}
+	rawURL := startArchiveServer(t, s)
+	cacheDir := t.TempDir()
+
+	interruptedDownload(t, s, rawURL, cacheDir)
+	archivePath, err := Download(rawURL, DownloadOptions{CacheDir: cacheDir, Timeout: testTimeout})
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if got := s.lastRange(); got == "" {
+		t.Errorf("second download did not try to resume")
+	}
+	// A 200 response carries the whole file, which must replace the partial one rather than extend it.
+	assertDownloaded(t, archivePath, s.content)
+}
+
+func TestDownloadRestartsWhenETagChanges(t *testing.T) {
+	s := &archiveServer{content: []byte("0123456789abcdefghij"), etag: `"v1"`}
+	rawURL := startArchiveServer(t, s)
+	cacheDir := t.TempDir()
+
+	interruptedDownload(t, s, rawURL, cacheDir)
+	s.set(func(s *archiveServer) {
+		s.content = []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		s.etag = `"v2"`
+	})
+	archivePath, err := Download(rawURL, DownloadOptions{CacheDir: cacheDir, Timeout: testTimeout})
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	// The server sees that If-Range no longer matches and sends the new file in full.
+	assertDownloaded(t, archivePath, []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ"))
+}
+
+func TestDownloadSanitisesContentDisposition(t *testing.T) {
+	tests := []struct {
+		disposition string
+		want        string
+	}{
+		{`attachment; filename="../x"`, "x"},
+		{`attachment; filename="../../etc/passwd"`, "passwd"},
+		{`attachment; filename="..\\..\\tool.zip"`, "tool.zip"},
+		{`attachment; filename="/tmp/tool.tar.xz"`, "tool.tar.xz"},
+		{`attachment; filename=".."`, "tool-1.0.tar.gz"},
+		{`attachment; filename=".hidden"`, "tool-1.0.tar.gz"},
+		{`attachment; filename="tool-2.0.tar.gz"`, "tool-2.0.tar.gz"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.disposition, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Disposition", tc.disposition)
+				w.Write([]byte("archive"))
+			}))
+			defer server.Close()
+			cacheDir := t.TempDir()
+
+			archivePath, err := Download(server.URL+"/tool-1.0.tar.gz", DownloadOptions{CacheDir: cacheDir, Timeout: testTimeout})
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+			if got := filepath.Base(archivePath); got != tc.want {
+				t.Errorf("saved as %s, want %s", got, tc.want)
+			}
+			if !isWithin(cacheDir, archivePath) {
+				t.Errorf("saved outside the cache: %s", archivePath)
+			}
+		})
+	}
+}
+
+func TestDownloadTimesOutWhenServerStalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"before headers", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}},
+		{"mid-body", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(tc.handler)
+			defer server.Close()
+
+			start := time.Now()
+			_, err := Download(server.URL+"/tool-1.0.tar.gz", DownloadOptions{CacheDir: t.TempDir(), Timeout: testTimeout})
+			if err == nil {
+				t.Fatalf("Download of a stalled server succeeded")
+			}
+			if elapsed := time.Since(start); elapsed > 10*testTimeout {
+				t.Errorf("gave up after %v, want about %v", elapsed, testTimeout)
+			}
+		})
+	}
+}
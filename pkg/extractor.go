@@ -2,16 +2,175 @@ package pkg
 
 import (
 	"archive/tar"
-	"compress/gzip"
+	"archive/zip"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+// ArchiveFormat identifies the container format of a package archive.
+type ArchiveFormat int
+
+const (
+	// FormatUnknown is returned when the archive's magic bytes are not recognised.
+	FormatUnknown ArchiveFormat = iota
+	// FormatTar is a tar archive, either uncompressed or wrapped in a registered compression format.
+	FormatTar
+	// FormatZip is a zip archive.
+	FormatZip
+)
+
+// String returns a human-readable name for the archive format.
+func (f ArchiveFormat) String() string {
+	switch f {
+	case FormatTar:
+		return "tar"
+	case FormatZip:
+		return "zip"
+	default:
+		return "unknown"
+	}
+}
+
+// Magic byte signatures used to identify archive formats regardless of file extension.
+// Compressed tarballs are recognised through the decompressors registered in decompress.go.
+var (
+	zipMagic = []byte{'P', 'K', 0x03, 0x04}
+	// zipEmptyMagic marks a zip archive with no entries (only an end of central directory record).
+	zipEmptyMagic = []byte{'P', 'K', 0x05, 0x06}
+	// ustarMagic appears at ustarOffset in the first header block of an uncompressed tar archive.
+	ustarMagic = []byte("ustar")
+)
+
+// ustarOffset is the position of the magic field within a tar header block.
+const ustarOffset = 257
+
+// DetectArchiveFormat determines the format of an archive by sniffing its leading magic bytes.
+// The filename is deliberately ignored so that misnamed or extensionless downloads still work.
+//
+// Parameters:
+//   - archivePath (string): The file system path to the archive.
+//
+// Returns:
+//   - ArchiveFormat: The detected format, or FormatUnknown if it is not recognised.
+//   - error: An error object if the archive cannot be read, otherwise nil.
+func DetectArchiveFormat(archivePath string) (ArchiveFormat, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return FormatUnknown, fmt.Errorf("error opening archive: %v", err)
+	}
+	defer file.Close()
+
+	// Read enough bytes to cover the tar header's magic field.
+	header := make([]byte, ustarOffset+len(ustarMagic))
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return FormatUnknown, fmt.Errorf("error reading archive header: %v", err)
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, zipMagic), bytes.HasPrefix(header, zipEmptyMagic):
+		return FormatZip, nil
+	case detectDecompressor(header) != nil:
+		return FormatTar, nil
+	case len(header) >= ustarOffset+len(ustarMagic) && bytes.Equal(header[ustarOffset:], ustarMagic):
+		return FormatTar, nil
+	default:
+		return FormatUnknown, nil
+	}
+}
+
+// ExtractReport summarises the outcome of an extraction.
+// Entries that were deliberately not extracted are recorded in Skipped rather than
+// printed as they are encountered, so callers can decide how to present them.
+type ExtractReport struct {
+	Files     int            // The number of regular files written.
+	Dirs      int            // The number of directories created.
+	Symlinks  int            // The number of symbolic links created.
+	Hardlinks int            // The number of hard links created.
+	Skipped   []SkippedEntry // Entries that were not extracted, with the reason for each.
+}
+
+// SkippedEntry describes an archive entry that was intentionally not extracted.
+type SkippedEntry struct {
+	Name   string // The entry name as recorded in the archive.
+	Reason string // Why the entry was skipped, e.g. "character device".
+}
+
+// skip records an entry that was not extracted.
+func (r *ExtractReport) skip(name, reason string) {
+	r.Skipped = append(r.Skipped, SkippedEntry{Name: name, Reason: reason})
+}
+
+// dirTime remembers the modification time of an extracted directory. Directory times
+// are applied once extraction finishes, since writing entries into a directory would
+// otherwise overwrite its timestamp.
+type dirTime struct {
+	path  string
+	mtime time.Time
+}
+
+// ExtractArchive extracts a supported archive to the specified destination directory,
+// choosing the extractor based on the archive's magic bytes.
+//
+// Before anything is written, the archive's headers are scanned and checked against
+// limits and against the free space of the target filesystem. If extraction then fails
+// for any reason, including a limit being hit mid-way, a destDir created by this call
+// is removed so that no partial installation is left behind.
+//
+// Parameters:
+//   - archivePath (string): The file system path to the archive.
+//   - destDir (string): The destination directory where the archive will be extracted.
+//   - limits (ExtractLimits): Resource limits to enforce during extraction.
+//
+// Returns:
+//   - *ExtractReport: A summary of what was extracted and skipped.
+//   - error: An error object if the format is unsupported, a limit is exceeded or extraction fails, otherwise nil.
+func ExtractArchive(archivePath, destDir string, limits ExtractLimits) (*ExtractReport, error) {
+	format, err := DetectArchiveFormat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatUnknown {
+		return nil, fmt.Errorf("unsupported archive format: %s", archivePath)
+	}
+
+	// Pre-flight: total up the declared sizes and make sure they fit.
+	stats, err := scanArchive(archivePath, format, limits)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFreeSpace(destDir, stats.totalSize); err != nil {
+		return nil, err
+	}
+
+	// Remember whether destDir already existed so that cleanup never removes a caller's directory.
+	_, statErr := os.Stat(destDir)
+	createdDestDir := os.IsNotExist(statErr)
+
+	var report *ExtractReport
+	switch format {
+	case FormatTar:
+		report, err = ExtractTar(archivePath, destDir, limits)
+	case FormatZip:
+		report, err = ExtractZip(archivePath, destDir, limits)
+	}
+
+	if err != nil {
+		if createdDestDir {
+			os.RemoveAll(destDir)
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
 // ExtractTarGz extracts a .tar.gz archive to the specified destination directory.
-// It handles the creation of directories and files, sets appropriate permissions,
-// and ensures that the extraction process is secure and efficient.
+// It is retained for compatibility and accepts any compression understood by ExtractTar.
 //
 // Parameters:
 //   - archivePath (string): The file system path to the .tar.gz archive.
@@ -20,27 +179,56 @@ import (
 // Returns:
 //   - error: An error object if the extraction fails, otherwise nil.
 func ExtractTarGz(archivePath, destDir string) error {
+	_, err := ExtractTar(archivePath, destDir, DefaultExtractLimits)
+	return err
+}
+
+// ExtractTar extracts a tar archive to the specified destination directory.
+// The compression format (gzip, bzip2, xz, zstd or none) is detected from the
+// stream header, and the decompressed stream is fed to a single tar-walking loop
+// that recreates directories, regular files, symlinks and hardlinks with their
+// permissions and modification times. Device nodes and FIFOs are never created.
+// Entries whose names or link targets would escape destDir are rejected with an *UnsafePathError.
+//
+// Parameters:
+//   - archivePath (string): The file system path to the tar archive.
+//   - destDir (string): The destination directory where the archive will be extracted.
+//   - limits (ExtractLimits): Resource limits to enforce while writing entries.
+//
+// Returns:
+//   - *ExtractReport: A summary of what was extracted and skipped.
+//   - error: An error object if the extraction fails or a limit is exceeded, otherwise nil.
+func ExtractTar(archivePath, destDir string, limits ExtractLimits) (*ExtractReport, error) {
 	// Open the archive file for reading.
 	file, err := os.Open(archivePath)
 	if err != nil {
-		return fmt.Errorf("error opening archive: %v", err)
+		return nil, fmt.Errorf("error opening archive: %v", err)
 	}
 	defer file.Close()
 
-	// Create a gzip reader to decompress the .tar.gz archive.
-	gzReader, err := gzip.NewReader(file)
+	// Wrap the file in a reader for whichever compression format it uses.
+	decompressed, _, err := NewDecompressingReader(file)
 	if err != nil {
-		return fmt.Errorf("error creating gzip reader: %v", err)
+		return nil, err
 	}
-	defer gzReader.Close()
+	defer decompressed.Close()
+
+	return extractTarStream(decompressed, destDir, limits)
+}
+
+// extractTarStream walks an uncompressed tar stream and writes its entries beneath destDir.
+func extractTarStream(r io.Reader, destDir string, limits ExtractLimits) (*ExtractReport, error) {
+	report := &ExtractReport{}
+	tracker := &limitTracker{limits: limits}
+	var dirTimes []dirTime
 
 	// Create a tar reader to read the decompressed archive contents.
-	tarReader := tar.NewReader(gzReader)
+	tarReader := tar.NewReader(r)
 
 	// Ensure that the destination directory exists; create it if necessary.
-	err = os.MkdirAll(destDir, os.ModePerm)
+	err := os.MkdirAll(destDir, os.ModePerm)
 	if err != nil {
-		return fmt.Errorf("error creating destination directory: %v", err)
+		return nil, fmt.Errorf("error creating destination directory: %v", err)
 	}
 
 	// Iterate through each entry in the tar archive.
@@ -53,51 +241,332 @@ func ExtractTarGz(archivePath, destDir string) error {
 		}
 
 		if err != nil {
-			return fmt.Errorf("error reading tar archive: %v", err)
+			return nil, fmt.Errorf("error reading tar archive: %v", err)
 		}
 
-		// Determine the full path for the current entry.
-		targetPath := filepath.Join(destDir, header.Name)
+		// Determine the full path for the current entry, rejecting entries that escape destDir.
+		targetPath, err := secureJoin(destDir, header.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		// Count the entry against the configured limits before touching the disk.
+		if err := tracker.addEntry(header.Name); err != nil {
+			return nil, err
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
 			// Handle directory entries by creating the directory.
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("error creating directory %s: %v", targetPath, err)
+			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode).Perm()); err != nil {
+				return nil, fmt.Errorf("error creating directory %s: %v", targetPath, err)
 			}
+			dirTimes = append(dirTimes, dirTime{path: targetPath, mtime: header.ModTime})
+			report.Dirs++
 
 		case tar.TypeReg:
 			// Handle regular file entries.
-			// Ensure that the parent directory exists.
-			if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
-				return fmt.Errorf("error creating directory for file %s: %v", targetPath, err)
+			if err := writeFile(targetPath, tracker.reader(header.Name, tarReader), os.FileMode(header.Mode).Perm(), header.ModTime); err != nil {
+				return nil, err
 			}
+			report.Files++
 
-			// Create the file with appropriate permissions.
-			outFile, err := os.Create(targetPath)
-			if err != nil {
-				return fmt.Errorf("error creating file %s: %v", targetPath, err)
+		case tar.TypeSymlink:
+			// Recreate symbolic links, provided they resolve inside destDir.
+			if err := createSymlink(destDir, header.Name, targetPath, header.Linkname); err != nil {
+				return nil, err
 			}
+			report.Symlinks++
 
-			// Copy the file contents from the archive to the newly created file.
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				outFile.Close()
-				return fmt.Errorf("error writing to file %s: %v", targetPath, err)
+		case tar.TypeLink:
+			// Recreate hard links to entries extracted earlier in the archive.
+			if err := createHardlink(destDir, header.Name, targetPath, header.Linkname); err != nil {
+				return nil, err
 			}
+			report.Hardlinks++
+
+		case tar.TypeChar:
+			report.skip(header.Name, "character device")
+
+		case tar.TypeBlock:
+			report.skip(header.Name, "block device")
+
+		case tar.TypeFifo:
+			report.skip(header.Name, "named pipe")
+
+		default:
+			// Skip any other entry types, such as vendor extensions.
+			report.skip(header.Name, fmt.Sprintf("unsupported entry type %q", header.Typeflag))
+		}
+	}
+
+	restoreDirTimes(dirTimes)
+	return report, nil
+}
+
+// ExtractZip extracts a .zip archive to the specified destination directory.
+// Unix permission bits stored in the zip external attributes are preserved, so
+// executables packaged on Unix-like systems remain executable after extraction.
+// Symlinks recorded by Unix zip tools are recreated, and modification times are kept.
+// Entries whose names or link targets would escape destDir are rejected with an *UnsafePathError.
+//
+// Parameters:
+//   - archivePath (string): The file system path to the .zip archive.
+//   - destDir (string): The destination directory where the archive will be extracted.
+//   - limits (ExtractLimits): Resource limits to enforce while writing entries.
+//
+// Returns:
+//   - *ExtractReport: A summary of what was extracted and skipped.
+//   - error: An error object if the extraction fails or a limit is exceeded, otherwise nil.
+func ExtractZip(archivePath, destDir string, limits ExtractLimits) (*ExtractReport, error) {
+	report := &ExtractReport{}
+	tracker := &limitTracker{limits: limits}
+	var dirTimes []dirTime
 
-			// Close the file to flush the write buffer.
-			outFile.Close()
+	// Open the zip archive; this reads the central directory up front.
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening zip archive: %v", err)
+	}
+	defer zipReader.Close()
 
-			// Set the file permissions as specified in the archive header.
-			if err := os.Chmod(targetPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("error setting permissions for file %s: %v", targetPath, err)
+	// Ensure that the destination directory exists; create it if necessary.
+	err = os.MkdirAll(destDir, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("error creating destination directory: %v", err)
+	}
+
+	// Iterate through each entry in the zip archive.
+	for _, f := range zipReader.File {
+		// Determine the full path for the current entry, rejecting entries that escape destDir.
+		targetPath, err := secureJoin(destDir, f.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		// Count the entry against the configured limits before touching the disk.
+		if err := tracker.addEntry(f.Name); err != nil {
+			return nil, err
+		}
+		mode := zipFileMode(f)
+
+		switch {
+		case mode.IsDir():
+			// Handle directory entries by creating the directory.
+			if err := os.MkdirAll(targetPath, mode.Perm()); err != nil {
+				return nil, fmt.Errorf("error creating directory %s: %v", targetPath, err)
+			}
+			dirTimes = append(dirTimes, dirTime{path: targetPath, mtime: f.Modified})
+			report.Dirs++
+
+		case mode.IsRegular():
+			// Handle regular file entries.
+			if err := extractZipFile(f, targetPath, mode.Perm(), tracker); err != nil {
+				return nil, err
 			}
+			report.Files++
+
+		case mode&os.ModeSymlink != 0:
+			// Zip stores a symlink's target as the entry's contents.
+			linkTarget, err := readZipEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			if err := createSymlink(destDir, f.Name, targetPath, linkTarget); err != nil {
+				return nil, err
+			}
+			report.Symlinks++
 
 		default:
-			// Skip any unknown file types and inform the user.
-			fmt.Printf("Skipping unknown type: %v in %s\n", header.Typeflag, header.Name)
+			// Skip devices, pipes and any other special entries.
+			report.skip(f.Name, fmt.Sprintf("unsupported file type %v", mode.Type()))
+		}
+	}
+
+	restoreDirTimes(dirTimes)
+	return report, nil
+}
+
+// extractZipFile writes a single regular file entry from a zip archive to targetPath
+// and applies the given permissions, counting the bytes written against tracker.
+func extractZipFile(f *zip.File, targetPath string, perm os.FileMode, tracker *limitTracker) error {
+	// Open the compressed entry for reading.
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("error opening zip entry %s: %v", f.Name, err)
+	}
+	defer rc.Close()
+
+	return writeFile(targetPath, tracker.reader(f.Name, rc), perm, f.Modified)
+}
+
+// readZipEntry returns the full contents of a small zip entry, such as a symlink target.
+func readZipEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("error opening zip entry %s: %v", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return "", fmt.Errorf("error reading zip entry %s: %v", f.Name, err)
+	}
+	return string(data), nil
+}
+
+// writeFile copies src into a new regular file at targetPath, then applies the
+// given permissions and modification time.
+func writeFile(targetPath string, src io.Reader, perm os.FileMode, mtime time.Time) error {
+	// Ensure that the parent directory exists.
+	if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory for file %s: %v", targetPath, err)
+	}
+
+	// Replace any link left by an earlier entry rather than writing through it.
+	if err := removeExistingLink(targetPath); err != nil {
+		return err
+	}
+
+	// Create the file to receive the entry's contents.
+	outFile, err := os.Create(targetPath)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %v", targetPath, err)
+	}
+
+	// Copy the contents from the archive to the newly created file.
+	if _, err := io.Copy(outFile, src); err != nil {
+		outFile.Close()
+		// Pass limit violations through unwrapped so callers can identify them.
+		if _, ok := err.(*LimitError); ok {
+			return err
+		}
+		return fmt.Errorf("error writing to file %s: %v", targetPath, err)
+	}
+
+	// Close the file to flush the write buffer.
+	outFile.Close()
+
+	// Set the file permissions as specified in the archive.
+	if err := os.Chmod(targetPath, perm); err != nil {
+		return fmt.Errorf("error setting permissions for file %s: %v", targetPath, err)
+	}
+
+	// Preserve the modification time recorded in the archive, when there is one.
+	if !mtime.IsZero() {
+		if err := os.Chtimes(targetPath, mtime, mtime); err != nil {
+			return fmt.Errorf("error setting modification time for file %s: %v", targetPath, err)
 		}
 	}
 
 	return nil
 }
+
+// createSymlink creates a symbolic link at targetPath pointing to linkTarget after
+// verifying that the target resolves inside destDir.
+func createSymlink(destDir, entry, targetPath, linkTarget string) error {
+	if err := validateLinkTarget(destDir, entry, targetPath, linkTarget, false); err != nil {
+		return err
+	}
+
+	// Ensure that the parent directory exists.
+	if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory for symlink %s: %v", targetPath, err)
+	}
+
+	// Archives may legitimately redefine a link; replace any earlier non-directory entry.
+	if err := removeExistingLink(targetPath); err != nil {
+		return err
+	}
+
+	if err := os.Symlink(linkTarget, targetPath); err != nil {
+		return fmt.Errorf("error creating symlink %s: %v", targetPath, err)
+	}
+	return nil
+}
+
+// createHardlink creates a hard link at targetPath to the previously extracted entry
+// named linkTarget, after verifying that the target resolves inside destDir.
+func createHardlink(destDir, entry, targetPath, linkTarget string) error {
+	if err := validateLinkTarget(destDir, entry, targetPath, linkTarget, true); err != nil {
+		return err
+	}
+
+	// Resolve the link target exactly as an entry name would be, including symlink checks.
+	sourcePath, err := secureJoin(destDir, linkTarget)
+	if err != nil {
+		return err
+	}
+
+	// Only regular files may be hard linked; linking to a symlink could escape destDir.
+	info, err := os.Lstat(sourcePath)
+	if err != nil {
+		return fmt.Errorf("error resolving hardlink target %s: %v", linkTarget, err)
+	}
+	if !info.Mode().IsRegular() {
+		return &UnsafePathError{Entry: entry, Target: linkTarget, Reason: "hardlink target is not a regular file"}
+	}
+
+	// Ensure that the parent directory exists.
+	if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory for hardlink %s: %v", targetPath, err)
+	}
+
+	if err := removeExistingLink(targetPath); err != nil {
+		return err
+	}
+
+	if err := os.Link(sourcePath, targetPath); err != nil {
+		return fmt.Errorf("error creating hardlink %s: %v", targetPath, err)
+	}
+	return nil
+}
+
+// removeExistingLink removes a symlink or file already present at path so that it
+// can be replaced. Directories are left alone and reported as an error.
+func removeExistingLink(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error inspecting %s: %v", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot replace directory %s with a non-directory entry", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("error replacing %s: %v", path, err)
+	}
+	return nil
+}
+
+// restoreDirTimes applies recorded directory modification times once every entry has
+// been written. Failures are ignored because timestamps are cosmetic and should never
+// fail an installation.
+func restoreDirTimes(dirTimes []dirTime) {
+	for _, dt := range dirTimes {
+		if dt.mtime.IsZero() {
+			continue
+		}
+		os.Chtimes(dt.path, dt.mtime, dt.mtime)
+	}
+}
+
+// zipFileMode returns the file mode of a zip entry.
+// Archives created on Unix store the full st_mode in the upper 16 bits of the
+// external attributes; for archives created elsewhere sensible defaults are used.
+func zipFileMode(f *zip.File) os.FileMode {
+	const creatorUnix = 3
+
+	if f.CreatorVersion>>8 == creatorUnix && f.ExternalAttrs>>16 != 0 {
+		// Let archive/zip translate the Unix st_mode into an os.FileMode.
+		return f.Mode()
+	}
+
+	// Fall back to conventional permissions when no Unix attributes are present.
+	if f.FileInfo().IsDir() || (len(f.Name) > 0 && f.Name[len(f.Name)-1] == '/') {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
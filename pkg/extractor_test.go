@@ -0,0 +1,271 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testEntry describes one entry of an archive built by a test.
+type testEntry struct {
+	name     string
+	body     string      // The contents of a regular file.
+	link     string      // The target of a symlink or hardlink.
+	typeflag byte        // A tar type flag; zip archives only use TypeReg, TypeDir and TypeSymlink.
+	mode     os.FileMode // The permission bits, 0644 when zero.
+}
+
+// writeTestTar writes a tar archive holding entries to a file in dir.
+func writeTestTar(t *testing.T, dir string, entries []testEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: int64(entryMode(e)), Linkname: e.link}
+		if e.typeflag == tar.TypeReg {
+			header.Size = int64(len(e.body))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("writing tar header %s: %v", e.name, err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatalf("writing tar entry %s: %v", e.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar writer: %v", err)
+	}
+	return writeTestArchive(t, dir, "test.tar", buf.Bytes())
+}
+
+// writeTestZip writes a zip archive holding entries to a file in dir, recording Unix
+// modes so that symlinks survive.
+func writeTestZip(t *testing.T, dir string, entries []testEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		content := e.body
+		switch e.typeflag {
+		case tar.TypeDir:
+			header.SetMode(os.ModeDir | entryMode(e))
+		case tar.TypeSymlink:
+			header.SetMode(os.ModeSymlink | 0777)
+			content = e.link
+		default:
+			header.SetMode(entryMode(e))
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("writing zip header %s: %v", e.name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("writing zip entry %s: %v", e.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("closing zip writer: %v", err)
+	}
+	return writeTestArchive(t, dir, "test.zip", buf.Bytes())
+}
+
+func entryMode(e testEntry) os.FileMode {
+	if e.mode != 0 {
+		return e.mode
+	}
+	if e.typeflag == tar.TypeDir {
+		return 0755
+	}
+	return 0644
+}
+
+func writeTestArchive(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	archivePath := filepath.Join(dir, name)
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatalf("writing archive: %v", err)
+	}
+	return archivePath
+}
+
+// listTree returns every path beneath root, relative to it.
+func listTree(t *testing.T, root string) map[string]bool {
+	t.Helper()
+	paths := map[string]bool{}
+	err := filepath.Walk(root, func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		paths[rel] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking %s: %v", root, err)
+	}
+	return paths
+}
+
+// maliciousArchives are archives that try to write or link outside the destination directory.
+var maliciousArchives = []struct {
+	name    string
+	entries []testEntry
+	zip     bool // Whether the archive can also be expressed as a zip; zip has no hardlinks.
+}{
+	{
+		name:    "parent traversal",
+		entries: []testEntry{{name: "../evil", body: "evil", typeflag: tar.TypeReg}},
+		zip:     true,
+	},
+	{
+		name:    "nested parent traversal",
+		entries: []testEntry{{name: "app/../../evil", body: "evil", typeflag: tar.TypeReg}},
+		zip:     true,
+	},
+	{
+		name:    "absolute path",
+		entries: []testEntry{{name: "/etc/evil", body: "evil", typeflag: tar.TypeReg}},
+		zip:     true,
+	},
+	{
+		name: "file beneath a symlinked directory",
+		entries: []testEntry{
+			{name: "dir", link: ".", typeflag: tar.TypeSymlink},
+			{name: "dir/evil", body: "evil", typeflag: tar.TypeReg},
+		},
+		zip: true,
+	},
+	{
+		name: "file beneath a symlink that escapes",
+		entries: []testEntry{
+			{name: "dir", link: "../outside", typeflag: tar.TypeSymlink},
+			{name: "dir/evil", body: "evil", typeflag: tar.TypeReg},
+		},
+		zip: true,
+	},
+	{
+		name:    "symlink escaping the destination",
+		entries: []testEntry{{name: "evil", link: "../../evil", typeflag: tar.TypeSymlink}},
+		zip:     true,
+	},
+	{
+		name:    "absolute symlink",
+		entries: []testEntry{{name: "evil", link: "/etc/passwd", typeflag: tar.TypeSymlink}},
+		zip:     true,
+	},
+	{
+		name: "symlink chained through another symlink",
+		entries: []testEntry{
+			{name: "a/b", link: "..", typeflag: tar.TypeSymlink},
+			{name: "evil", link: "a/b/../outside/secret", typeflag: tar.TypeSymlink},
+		},
+		zip: true,
+	},
+	{
+		name: "symlink through a symlink redefined later",
+		entries: []testEntry{
+			{name: "a/b", link: "../c/d/e", typeflag: tar.TypeSymlink},
+			{name: "evil", link: "a/b/../../outside/secret", typeflag: tar.TypeSymlink},
+			{name: "a/b", link: ".", typeflag: tar.TypeSymlink},
+		},
+		zip: true,
+	},
+	{
+		name: "symlink through a path created later",
+		entries: []testEntry{
+			{name: "evil", link: "a/b/../outside/secret", typeflag: tar.TypeSymlink},
+			{name: "a/b", link: "..", typeflag: tar.TypeSymlink},
+		},
+		zip: true,
+	},
+	{
+		name:    "hardlink escaping the destination",
+		entries: []testEntry{{name: "evil", link: "../outside/secret", typeflag: tar.TypeLink}},
+	},
+	{
+		name:    "absolute hardlink",
+		entries: []testEntry{{name: "evil", link: "/etc/passwd", typeflag: tar.TypeLink}},
+	},
+}
+
+func TestExtractArchiveRejectsUnsafeEntries(t *testing.T) {
+	for _, tc := range maliciousArchives {
+		formats := []string{"tar"}
+		if tc.zip {
+			formats = append(formats, "zip")
+		}
+		for _, format := range formats {
+			t.Run(tc.name+"/"+format, func(t *testing.T) {
+				root := t.TempDir()
+				archiveDir := filepath.Join(root, "archive")
+				destDir := filepath.Join(root, "parent", "dest")
+				outside := filepath.Join(root, "parent", "outside")
+				for _, dir := range []string{archiveDir, outside} {
+					if err := os.MkdirAll(dir, 0755); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				var archivePath string
+				if format == "zip" {
+					archivePath = writeTestZip(t, archiveDir, tc.entries)
+				} else {
+					archivePath = writeTestTar(t, archiveDir, tc.entries)
+				}
+				before := listTree(t, root)
+
+				_, err := ExtractArchive(archivePath, destDir, DefaultExtractLimits)
+				var unsafe *UnsafePathError
+				if !errors.As(err, &unsafe) {
+					t.Fatalf("ExtractArchive returned %v, want an *UnsafePathError", err)
+				}
+
+				// The failed extraction removes the destination it created and writes nothing else.
+				after := listTree(t, root)
+				for path := range after {
+					if !before[path] {
+						t.Errorf("extraction left %s behind", path)
+					}
+				}
+				if _, err := os.Lstat("/etc/evil"); err == nil {
+					t.Errorf("extraction wrote /etc/evil")
+				}
+			})
+		}
+	}
+}
+
+func TestExtractArchiveKeepsSafeLinks(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := writeTestTar(t, dir, []testEntry{
+		{name: "app/lib", typeflag: tar.TypeDir},
+		{name: "app/lib/libfoo.so.1", body: "library", typeflag: tar.TypeReg},
+		{name: "app/lib/libfoo.so", link: "libfoo.so.1", typeflag: tar.TypeSymlink},
+		{name: "app/bin/foo", link: "../lib/libfoo.so.1", typeflag: tar.TypeSymlink},
+		{name: "app/lib/libfoo.copy", link: "app/lib/libfoo.so.1", typeflag: tar.TypeLink},
+	})
+	destDir := filepath.Join(dir, "dest")
+
+	report, err := ExtractArchive(archivePath, destDir, DefaultExtractLimits)
+	if err != nil {
+		t.Fatalf("ExtractArchive: %v", err)
+	}
+	if report.Symlinks != 2 || report.Hardlinks != 1 {
+		t.Errorf("extracted %d symlinks and %d hardlinks, want 2 and 1", report.Symlinks, report.Hardlinks)
+	}
+	data, err := os.ReadFile(filepath.Join(destDir, "app", "bin", "foo"))
+	if err != nil || string(data) != "library" {
+		t.Errorf("reading through app/bin/foo = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultRetainedGenerations is the number of previous installations kept for rollback
+// when a package is reinstalled or upgraded.
+const DefaultRetainedGenerations = 2
+
+// NextGeneration makes next the current installation of a package, demoting current to
+// a retained generation. Only the keep most recently installed generations are retained;
+// the rest are returned so that the caller can delete their installation directories.
+//
+// Parameters:
+//   - current (Package): The package record as it is currently installed.
+//   - next (Package): The record describing the newly installed payload.
+//   - keep (int): The number of previous generations to retain.
+//
+// Returns:
+//   - Package: The updated package record, with next as the current installation.
+//   - []Package: Generations that are no longer retained.
+func NextGeneration(current, next Package, keep int) (Package, []Package) {
+	generations := append([]Package{current.withoutGenerations()}, current.Generations...)
+	next.Generations = nil
+	next.Generations, generations = retainGenerations(generations, keep)
+	return next, generations
+}
+
+// RollbackGeneration makes a retained generation the current installation of a package.
+// The installation being replaced is kept as a generation, so it can be restored later.
+//
+// Parameters:
+//   - current (Package): The package record as it is currently installed.
+//   - version (string): The version to roll back to, or "" for the generation installed just before the current one.
+//
+// Returns:
+//   - Package: The updated package record, with the chosen generation as the current installation.
+//   - error: An error object if there is no suitable generation, otherwise nil.
+func RollbackGeneration(current Package, version string) (Package, error) {
+	index := -1
+	for i, generation := range current.Generations {
+		if version != "" {
+			if generation.Version == version {
+				index = i
+				break
+			}
+			continue
+		}
+
+		// Pick the newest generation installed before the current one, so that repeated
+		// rollbacks keep stepping further back in time.
+		if generation.InstalledAt.After(current.InstalledAt) {
+			continue
+		}
+		if index == -1 || generation.InstalledAt.After(current.Generations[index].InstalledAt) {
+			index = i
+		}
+	}
+
+	if index == -1 {
+		if version != "" {
+			return Package{}, fmt.Errorf("no retained generation of %s has version %s", current.Name, version)
+		}
+		return Package{}, fmt.Errorf("no earlier generation of %s is retained", current.Name)
+	}
+
+	target := current.Generations[index]
+	var generations []Package
+	generations = append(generations, current.Generations[:index]...)
+	generations = append(generations, current.Generations[index+1:]...)
+	generations = append(generations, current.withoutGenerations())
+
+	// Keep generations ordered newest first for display.
+	sort.SliceStable(generations, func(i, j int) bool {
+		return generations[i].InstalledAt.After(generations[j].InstalledAt)
+	})
+
+	target.Generations = generations
+	return target, nil
+}
+
+// retainGenerations sorts generations newest first and splits them into those that
+// are kept and those beyond the retention limit.
+func retainGenerations(generations []Package, keep int) ([]Package, []Package) {
+	sort.SliceStable(generations, func(i, j int) bool {
+		return generations[i].InstalledAt.After(generations[j].InstalledAt)
+	})
+	if keep < 0 {
+		keep = 0
+	}
+	if len(generations) <= keep {
+		return generations, nil
+	}
+	return generations[:keep], generations[keep:]
+}
+
+// withoutGenerations returns a copy of the package record with its generations removed.
+func (p Package) withoutGenerations() Package {
+	p.Generations = nil
+	return p
+}
+
+// InstallPaths returns the installation directories of the package and all of its
+// retained generations.
+//
+// Returns:
+//   - []string: The current installation path followed by those of each generation.
+func (p Package) InstallPaths() []string {
+	paths := []string{p.InstallPath}
+	for _, generation := range p.Generations {
+		paths = append(paths, generation.InstallPath)
+	}
+	return paths
+}
+
+// DesktopOptionsFor rebuilds the .desktop metadata recorded for a package, so that its
+// desktop entry can be regenerated without the original manifest.
+//
+// Parameters:
+//   - p (Package): The package record.
+//
+// Returns:
+//   - *DesktopOptions: The desktop options recorded for the package.
+func DesktopOptionsFor(p Package) *DesktopOptions {
+	opts := &DesktopOptions{
+		Icon:       p.Icon,
+		Comment:    p.Description,
+		Categories: p.Categories,
+	}
+	if p.Desktop != nil {
+		opts.Entry = *p.Desktop
+	}
+	return opts
+}
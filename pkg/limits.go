@@ -0,0 +1,199 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ExtractLimits bounds the resources an extraction may consume, protecting the system
+// from decompression bombs and oversized archives. A zero value for any field disables
+// that particular limit.
+type ExtractLimits struct {
+	MaxTotalSize int64 // The maximum combined uncompressed size of all files, in bytes.
+	MaxFileSize  int64 // The maximum uncompressed size of any single file, in bytes.
+	MaxEntries   int64 // The maximum number of entries the archive may contain.
+}
+
+// DefaultExtractLimits are generous enough for large application bundles such as JDKs
+// and Electron apps while still stopping runaway archives well before the disk fills.
+var DefaultExtractLimits = ExtractLimits{
+	MaxTotalSize: 16 << 30, // 16 GiB
+	MaxFileSize:  8 << 30,  // 8 GiB
+	MaxEntries:   500000,
+}
+
+// LimitError is returned when an archive exceeds one of the configured ExtractLimits
+// or would not fit in the free space of the target filesystem.
+type LimitError struct {
+	Limit string // Which limit was exceeded, e.g. "total size".
+	Entry string // The entry being processed when the limit was hit, if any.
+	Value int64  // The value that exceeded the limit.
+	Max   int64  // The configured maximum.
+}
+
+// Error implements the error interface.
+func (e *LimitError) Error() string {
+	if e.Limit == "free space" {
+		return fmt.Sprintf("insufficient free space: archive needs %d bytes but only %d are available", e.Value, e.Max)
+	}
+	if e.Entry != "" {
+		return fmt.Sprintf("archive exceeds %s limit at entry %q (%d > %d)", e.Limit, e.Entry, e.Value, e.Max)
+	}
+	return fmt.Sprintf("archive exceeds %s limit (%d > %d)", e.Limit, e.Value, e.Max)
+}
+
+// limitTracker enforces ExtractLimits while an archive is being written to disk.
+// It is consulted for every entry and wraps every file's content reader.
+type limitTracker struct {
+	limits  ExtractLimits
+	entries int64
+	total   int64
+}
+
+// addEntry counts one archive entry against the entry limit.
+func (t *limitTracker) addEntry(name string) error {
+	t.entries++
+	if t.limits.MaxEntries > 0 && t.entries > t.limits.MaxEntries {
+		return &LimitError{Limit: "entry count", Entry: name, Value: t.entries, Max: t.limits.MaxEntries}
+	}
+	return nil
+}
+
+// reader wraps r so that reading beyond the per-file or total size limit fails with a LimitError.
+// The declared sizes in archive headers cannot be trusted, so the bytes actually produced are counted.
+func (t *limitTracker) reader(name string, r io.Reader) io.Reader {
+	return &limitedReader{tracker: t, name: name, r: r}
+}
+
+// limitedReader counts the bytes read from an archive entry against a limitTracker.
+type limitedReader struct {
+	tracker *limitTracker
+	name    string
+	r       io.Reader
+	n       int64
+}
+
+// Read implements io.Reader.
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.n += int64(n)
+	lr.tracker.total += int64(n)
+
+	limits := lr.tracker.limits
+	if limits.MaxFileSize > 0 && lr.n > limits.MaxFileSize {
+		return n, &LimitError{Limit: "file size", Entry: lr.name, Value: lr.n, Max: limits.MaxFileSize}
+	}
+	if limits.MaxTotalSize > 0 && lr.tracker.total > limits.MaxTotalSize {
+		return n, &LimitError{Limit: "total size", Entry: lr.name, Value: lr.tracker.total, Max: limits.MaxTotalSize}
+	}
+	return n, err
+}
+
+// archiveStats holds the sizes declared by an archive's headers.
+type archiveStats struct {
+	entries   int64
+	totalSize int64
+}
+
+// scanArchive reads an archive's headers without writing anything to disk and totals
+// the declared entry count and uncompressed size. Scanning stops as soon as a limit
+// is exceeded, so a decompression bomb is never fully expanded.
+func scanArchive(archivePath string, format ArchiveFormat, limits ExtractLimits) (*archiveStats, error) {
+	stats := &archiveStats{}
+
+	// check applies the limits to a single declared entry and the running totals.
+	check := func(name string, size int64) error {
+		stats.entries++
+		stats.totalSize += size
+		if limits.MaxEntries > 0 && stats.entries > limits.MaxEntries {
+			return &LimitError{Limit: "entry count", Entry: name, Value: stats.entries, Max: limits.MaxEntries}
+		}
+		if limits.MaxFileSize > 0 && size > limits.MaxFileSize {
+			return &LimitError{Limit: "file size", Entry: name, Value: size, Max: limits.MaxFileSize}
+		}
+		if limits.MaxTotalSize > 0 && stats.totalSize > limits.MaxTotalSize {
+			return &LimitError{Limit: "total size", Entry: name, Value: stats.totalSize, Max: limits.MaxTotalSize}
+		}
+		return nil
+	}
+
+	switch format {
+	case FormatZip:
+		// The zip central directory lists every entry up front, so no decompression is needed.
+		zipReader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("error opening zip archive: %v", err)
+		}
+		defer zipReader.Close()
+
+		for _, f := range zipReader.File {
+			if err := check(f.Name, int64(f.UncompressedSize64)); err != nil {
+				return nil, err
+			}
+		}
+
+	case FormatTar:
+		// Tar headers are interleaved with the data, so the stream has to be walked.
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("error opening archive: %v", err)
+		}
+		defer file.Close()
+
+		decompressed, _, err := NewDecompressingReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer decompressed.Close()
+
+		tarReader := tar.NewReader(decompressed)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading tar archive: %v", err)
+			}
+			if err := check(header.Name, header.Size); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", archivePath)
+	}
+
+	return stats, nil
+}
+
+// checkFreeSpace verifies that the filesystem which will hold destDir has room for
+// the given number of bytes. The check is skipped on platforms where free space
+// cannot be determined.
+func checkFreeSpace(destDir string, required int64) error {
+	// Walk up to the nearest existing ancestor, since destDir is usually not created yet.
+	dir := destDir
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+
+	available, ok, err := availableBytes(dir)
+	if err != nil {
+		return fmt.Errorf("error checking free space on %s: %v", dir, err)
+	}
+	if ok && required > available {
+		return &LimitError{Limit: "free space", Value: required, Max: available}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ReplaceSymlink atomically points linkPath at target. A temporary link is created
+// next to linkPath and renamed over it, so there is never a moment when linkPath is
+// missing or half-updated.
+//
+// Parameters:
+//   - target (string): The path the symlink should point to.
+//   - linkPath (string): The location of the symlink to create or replace.
+//
+// Returns:
+//   - error: An error object if the link cannot be created or renamed into place, otherwise nil.
+func ReplaceSymlink(target, linkPath string) error {
+	tempPath := filepath.Join(filepath.Dir(linkPath), fmt.Sprintf(".%s.tmp-%d", filepath.Base(linkPath), os.Getpid()))
+
+	// Clear any temporary link left behind by an interrupted run.
+	os.Remove(tempPath)
+
+	if err := os.Symlink(target, tempPath); err != nil {
+		return fmt.Errorf("error creating temporary symlink: %v", err)
+	}
+	if err := os.Rename(tempPath, linkPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("error replacing symlink %s: %v", linkPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockFileName is the name of the lock file kept in the store directory.
+const LockFileName = ".lock"
+
+// DefaultLockTimeout is how long a command waits for another process to release the lock.
+const DefaultLockTimeout = 30 * time.Second
+
+// LockMode selects between shared and exclusive locking of the package database.
+type LockMode int
+
+const (
+	// SharedLock is held by read-only commands; any number of processes may hold it at once.
+	SharedLock LockMode = iota
+	// ExclusiveLock is held by commands that modify packages; it excludes every other lock.
+	ExclusiveLock
+)
+
+// errLockBusy is returned by tryLock when another process holds a conflicting lock.
+var errLockBusy = errors.New("lock is held by another process")
+
+// Lock is an advisory lock on a store directory, held from AcquireLock until Release
+// or until the process exits. It serialises processes that would otherwise load the
+// database, modify it and save it over each other's changes.
+type Lock struct {
+	file *os.File
+	mode LockMode
+}
+
+// LockedError reports that another process held the lock for longer than the timeout.
+type LockedError struct {
+	Path string // The lock file.
+	PID  int    // The process holding an exclusive lock, or 0 if unknown.
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("another packagemanager process (pid %d) is running", e.PID)
+	}
+	return "another packagemanager process is running"
+}
+
+// AcquireLock takes an advisory lock on the store directory, waiting up to timeout for
+// other processes to release a conflicting lock. The holder of an exclusive lock records
+// its process ID in the lock file so that waiting processes can report it. A shared lock
+// never creates the store directory or lock file: where they do not exist there is no
+// database to protect, and the lock is held without a file.
+//
+// Parameters:
+//   - storeDir (string): The store directory whose database is being locked.
+//   - mode (LockMode): SharedLock for read-only commands, ExclusiveLock for commands that modify packages.
+//   - timeout (time.Duration): How long to wait for the lock; zero fails immediately if it is held.
+//
+// Returns:
+//   - *Lock: The held lock.
+//   - error: A *LockedError if the lock could not be taken before the timeout, or another error on failure.
+func AcquireLock(storeDir string, mode LockMode, timeout time.Duration) (*Lock, error) {
+	if mode == ExclusiveLock {
+		if err := os.MkdirAll(storeDir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating store directory: %v", err)
+		}
+	}
+
+	lockPath := filepath.Join(storeDir, LockFileName)
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil && mode == SharedLock {
+		// Readers without write access may still lock a read-only descriptor.
+		file, err = os.Open(lockPath)
+		if os.IsNotExist(err) {
+			// No store yet, or a read-only one that has never been modified.
+			return &Lock{mode: mode}, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		err := tryLock(file, mode)
+		if err == nil {
+			break
+		}
+		if err != errLockBusy {
+			file.Close()
+			return nil, fmt.Errorf("error locking %s: %v", lockPath, err)
+		}
+
+		pid := lockHolder(lockPath)
+		if !time.Now().Before(deadline) {
+			file.Close()
+			return nil, &LockedError{Path: lockPath, PID: pid}
+		}
+		if !waiting {
+			waiting = true
+			if pid > 0 {
+				fmt.Printf("Waiting for another packagemanager process (pid %d) to finish...\n", pid)
+			} else {
+				fmt.Println("Waiting for another packagemanager process to finish...")
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if mode == ExclusiveLock {
+		file.Truncate(0)
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{file: file, mode: mode}, nil
+}
+
+// Release unlocks the store directory. The lock is also released automatically when
+// the process exits, so commands that exit early do not leave it held.
+//
+// Returns:
+//   - error: An error object if the lock could not be released, otherwise nil.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	if l.mode == ExclusiveLock {
+		// Clear the process ID so that it is not reported once this process has finished.
+		l.file.Truncate(0)
+	}
+	err := unlock(l.file)
+	l.file.Close()
+	l.file = nil
+	return err
+}
+
+// lockHolder reads the process ID recorded by the holder of an exclusive lock.
+func lockHolder(lockPath string) int {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
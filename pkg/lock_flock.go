@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package pkg
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLock attempts to flock the file without blocking.
+func tryLock(file *os.File, mode LockMode) error {
+	how := syscall.LOCK_SH
+	if mode == ExclusiveLock {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errLockBusy
+	}
+	return err
+}
+
+// unlock releases a flock taken by tryLock.
+func unlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package pkg
+
+import "os"
+
+// tryLock is not implemented on this platform, so concurrent processes are not serialised.
+func tryLock(file *os.File, mode LockMode) error {
+	return nil
+}
+
+// unlock is not implemented on this platform.
+func unlock(file *os.File) error {
+	return nil
+}
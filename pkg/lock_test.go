@@ -0,0 +1,44 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSharedLockOnMissingStore(t *testing.T) {
+	storeDir := filepath.Join(t.TempDir(), "store")
+
+	lock, err := AcquireLock(storeDir, SharedLock, 0)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("Release: %v", err)
+	}
+	if _, err := os.Stat(storeDir); !os.IsNotExist(err) {
+		t.Errorf("shared lock created the store directory")
+	}
+}
+
+func TestSharedLockOnReadOnlyStore(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	storeDir := t.TempDir()
+	if err := os.Chmod(storeDir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(storeDir, 0755) })
+
+	lock, err := AcquireLock(storeDir, SharedLock, 0)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("Release: %v", err)
+	}
+	if _, err := AcquireLock(storeDir, ExclusiveLock, 0); err == nil {
+		t.Errorf("exclusive lock taken on a read-only store")
+	}
+}
@@ -0,0 +1,201 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ManifestFileNames lists the manifest files recognised at the root of a package, in order of preference.
+var ManifestFileNames = []string{"package.toml", "package.json"}
+
+// Manifest describes a package as declared by its author in a package.toml or
+// package.json file shipped inside the archive. When present, it allows a package
+// to be installed without prompting for a name or an executable.
+type Manifest struct {
+	Name        string       `json:"name" toml:"name"`               // The user-friendly name of the package.
+	Version     string       `json:"version" toml:"version"`         // The version of the packaged software.
+	Description string       `json:"description" toml:"description"` // A one-line description of the package.
+	Executables []string     `json:"executables" toml:"executables"` // Executables to link into the bin directory, relative to the package root. The first is the main executable.
+	Icon        string       `json:"icon" toml:"icon"`               // The application icon, relative to the package root.
+	Categories  []string     `json:"categories" toml:"categories"`   // Desktop menu categories, e.g. ["Development", "IDE"].
+	Desktop     DesktopEntry `json:"desktop" toml:"desktop"`         // Additional .desktop entry metadata.
+}
+
+// DesktopEntry holds optional metadata written into a package's .desktop file.
+// Empty fields are omitted from the generated file.
+type DesktopEntry struct {
+	GenericName    string   `json:"generic_name" toml:"generic_name"`         // A generic name, e.g. "Web Browser".
+	Comment        string   `json:"comment" toml:"comment"`                   // A tooltip for the menu entry; defaults to the package description.
+	Terminal       bool     `json:"terminal" toml:"terminal"`                 // Whether the application runs in a terminal.
+	NoDisplay      bool     `json:"no_display" toml:"no_display"`             // Whether to hide the entry from application menus.
+	Keywords       []string `json:"keywords" toml:"keywords"`                 // Additional search keywords.
+	MimeTypes      []string `json:"mime_types" toml:"mime_types"`             // MIME types the application can open.
+	StartupWMClass string   `json:"startup_wm_class" toml:"startup_wm_class"` // The WM_CLASS used to group windows with the launcher.
+	Args           string   `json:"args" toml:"args"`                         // Arguments appended to Exec=, e.g. "%U".
+}
+
+// LoadManifest looks for a package manifest in an extracted package. The manifest may
+// sit at the top of installPath or, for archives that wrap everything in a single
+// top-level directory, inside that directory.
+//
+// Parameters:
+//   - installPath (string): The directory the archive was extracted to.
+//
+// Returns:
+//   - *Manifest: The parsed manifest, or nil if the package does not ship one.
+//   - string: The package root that relative paths in the manifest are resolved against.
+//   - error: An error object if a manifest exists but is invalid, otherwise nil.
+func LoadManifest(installPath string) (*Manifest, string, error) {
+	for _, root := range manifestRoots(installPath) {
+		for _, name := range ManifestFileNames {
+			manifestPath := filepath.Join(root, name)
+			if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+				continue
+			}
+
+			manifest, err := parseManifest(manifestPath)
+			if err != nil {
+				return nil, "", err
+			}
+			if err := manifest.validate(root); err != nil {
+				return nil, "", fmt.Errorf("invalid manifest %s: %v", manifestPath, err)
+			}
+			return manifest, root, nil
+		}
+	}
+
+	return nil, installPath, nil
+}
+
+// manifestRoots returns the directories searched for a manifest: installPath itself,
+// followed by its only child when the archive contained a single top-level directory.
+func manifestRoots(installPath string) []string {
+	roots := []string{installPath}
+
+	entries, err := os.ReadDir(installPath)
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		roots = append(roots, filepath.Join(installPath, entries[0].Name()))
+	}
+	return roots
+}
+
+// parseManifest decodes a manifest file, selecting TOML or JSON by its extension.
+func parseManifest(manifestPath string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %v", err)
+	}
+
+	manifest := &Manifest{}
+	switch filepath.Ext(manifestPath) {
+	case ".toml":
+		if err := toml.Unmarshal(data, manifest); err != nil {
+			return nil, fmt.Errorf("error parsing manifest %s: %v", manifestPath, err)
+		}
+	default:
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return nil, fmt.Errorf("error parsing manifest %s: %v", manifestPath, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// validate checks that every path in the manifest stays inside root and that the
+// declared executables exist.
+func (m *Manifest) validate(root string) error {
+	for _, exe := range m.Executables {
+		path, err := m.resolve(root, exe)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("executable %s: %v", exe, err)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("executable %s is not a regular file", exe)
+		}
+	}
+
+	if m.Icon != "" {
+		if _, err := m.resolve(root, m.Icon); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolve turns a manifest-relative path into an absolute path inside root.
+func (m *Manifest) resolve(root, rel string) (string, error) {
+	path, err := secureJoin(root, rel)
+	if err != nil {
+		return "", fmt.Errorf("path %q: %v", rel, err)
+	}
+	return path, nil
+}
+
+// ExecutablePaths returns the absolute paths of the executables declared by the manifest.
+//
+// Parameters:
+//   - root (string): The package root returned by LoadManifest.
+//
+// Returns:
+//   - []string: The resolved executable paths; the first is the main executable.
+func (m *Manifest) ExecutablePaths(root string) []string {
+	paths := []string{}
+	for _, exe := range m.Executables {
+		if path, err := m.resolve(root, exe); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// IconPath returns the absolute path of the manifest's icon, or an empty string if none is declared.
+//
+// Parameters:
+//   - root (string): The package root returned by LoadManifest.
+//
+// Returns:
+//   - string: The resolved icon path, or "".
+func (m *Manifest) IconPath(root string) string {
+	if m.Icon == "" {
+		return ""
+	}
+	path, err := m.resolve(root, m.Icon)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// ResolvePackageFile finds a regular file inside an extracted package from a path given
+// relative to the package, as with the install command's --exec flag. The path is tried
+// against installPath and, for archives with a single top-level directory, against that
+// directory as well.
+//
+// Parameters:
+//   - installPath (string): The directory the archive was extracted to.
+//   - rel (string): The path of the file relative to the package.
+//
+// Returns:
+//   - string: The absolute path of the file.
+//   - error: An error object if the path escapes the package or no such file exists, otherwise nil.
+func ResolvePackageFile(installPath, rel string) (string, error) {
+	for _, root := range manifestRoots(installPath) {
+		path, err := secureJoin(root, rel)
+		if err != nil {
+			return "", fmt.Errorf("path %q: %v", rel, err)
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in the package", rel)
+}
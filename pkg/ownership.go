@@ -0,0 +1,295 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Types of filesystem object recorded in a package's ownership manifest.
+const (
+	OwnedRegularFile = "file"
+	OwnedDirectory   = "dir"
+	OwnedSymlink     = "symlink"
+)
+
+// OwnedFile describes a filesystem object created by a package: a file, directory or
+// symlink extracted into its installation directory, or one of the symlinks and
+// .desktop entries that integrate it with the system.
+type OwnedFile struct {
+	Path   string      `json:"path"`             // The absolute path of the object.
+	Type   string      `json:"type"`             // One of OwnedRegularFile, OwnedDirectory or OwnedSymlink.
+	Mode   fs.FileMode `json:"mode"`             // The permission bits of the object.
+	Size   int64       `json:"size,omitempty"`   // The size of a regular file in bytes.
+	SHA256 string      `json:"sha256,omitempty"` // The hex-encoded SHA-256 digest of a regular file.
+	Target string      `json:"target,omitempty"` // The target of a symlink.
+}
+
+// DescribeFile records the current state of a filesystem object, hashing its contents
+// if it is a regular file.
+//
+// Parameters:
+//   - path (string): The path of the object.
+//
+// Returns:
+//   - OwnedFile: The description of the object.
+//   - error: An error object if the object cannot be read or is of an unsupported type, otherwise nil.
+func DescribeFile(path string) (OwnedFile, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return OwnedFile{}, err
+	}
+
+	owned := OwnedFile{Path: path, Mode: info.Mode().Perm()}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		owned.Type = OwnedSymlink
+		if owned.Target, err = os.Readlink(path); err != nil {
+			return OwnedFile{}, err
+		}
+	case info.IsDir():
+		owned.Type = OwnedDirectory
+	case info.Mode().IsRegular():
+		owned.Type = OwnedRegularFile
+		owned.Size = info.Size()
+		if owned.SHA256, err = HashFile(path); err != nil {
+			return OwnedFile{}, err
+		}
+	default:
+		return OwnedFile{}, fmt.Errorf("%s is not a regular file, directory or symlink", path)
+	}
+	return owned, nil
+}
+
+// HashFile computes the hex-encoded SHA-256 digest of a file's contents.
+//
+// Parameters:
+//   - path (string): The path of the file.
+//
+// Returns:
+//   - string: The digest.
+//   - error: An error object if the file cannot be read, otherwise nil.
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ScanInstallTree describes every object in an installation directory, including the
+// directory itself, in lexical order.
+//
+// Parameters:
+//   - installPath (string): The installation directory.
+//
+// Returns:
+//   - []OwnedFile: The objects found.
+//   - error: An error object if the tree cannot be read, otherwise nil.
+func ScanInstallTree(installPath string) ([]OwnedFile, error) {
+	var files []OwnedFile
+	err := filepath.WalkDir(installPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		owned, err := DescribeFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, owned)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning %s: %v", installPath, err)
+	}
+	return files, nil
+}
+
+// RecordOwnedFiles fills in the package's ownership manifest: every object in its
+// installation directory, plus the symlinks and .desktop entry that currently integrate
+// it with the system. Call it once those have been created. Retained generations keep
+// only their installation directory contents, since the integration files belong to the
+// current installation.
+//
+// Parameters:
+//   - cfg (*Config): The configuration giving the bin and desktop directories.
+//
+// Returns:
+//   - error: An error object if a file cannot be described, otherwise nil.
+func (p *Package) RecordOwnedFiles(cfg *Config) error {
+	content := p.contentFiles()
+	if len(content) == 0 {
+		scanned, err := ScanInstallTree(p.InstallPath)
+		if err != nil {
+			return err
+		}
+		content = scanned
+	}
+
+	external := []OwnedFile{}
+	for _, path := range p.integrationPaths(cfg) {
+		owned, err := DescribeFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error recording %s: %v", path, err)
+		}
+		external = append(external, owned)
+	}
+
+	p.Files = append(content, external...)
+	for i := range p.Generations {
+		p.Generations[i].Files = p.Generations[i].contentFiles()
+	}
+	return nil
+}
+
+// ExternalFiles returns the objects the package owns outside its installation
+// directory, such as its symlinks and .desktop entry. Records created before ownership
+// manifests were introduced fall back to the paths those objects would have.
+//
+// Parameters:
+//   - cfg (*Config): The configuration giving the bin and desktop directories.
+//
+// Returns:
+//   - []OwnedFile: The owned objects outside the installation directory.
+func (p Package) ExternalFiles(cfg *Config) []OwnedFile {
+	if len(p.Files) == 0 {
+		var files []OwnedFile
+		for _, path := range p.integrationPaths(cfg) {
+			fileType := OwnedSymlink
+			if filepath.Ext(path) == ".desktop" {
+				fileType = OwnedRegularFile
+			}
+			files = append(files, OwnedFile{Path: path, Type: fileType})
+		}
+		return files
+	}
+
+	var files []OwnedFile
+	for _, owned := range p.Files {
+		if !isWithin(p.InstallPath, owned.Path) {
+			files = append(files, owned)
+		}
+	}
+	return files
+}
+
+// NotOwnedError reports that a file a package created outside its installation
+// directory has since been replaced by something the package does not own, such as a
+// symlink another package re-pointed at its own executable.
+type NotOwnedError struct {
+	Path    string // The path of the file.
+	Package string // The name of the package that created it.
+	Reason  string // Why the file no longer belongs to the package.
+}
+
+// Error returns the error message.
+func (e *NotOwnedError) Error() string {
+	return fmt.Sprintf("%s no longer belongs to %s: %s", e.Path, e.Package, e.Reason)
+}
+
+// CheckOwnership reports whether a file the package created outside its installation
+// directory still belongs to it, and so is safe to remove. A symlink belongs to the
+// package while it resolves into one of the package's installation directories. A
+// .desktop entry belongs to it if it is unchanged since it was recorded, or if it still
+// launches an executable from the package.
+//
+// Parameters:
+//   - cfg (*Config): The configuration, used to resolve paths inside an alternate root.
+//   - owned (OwnedFile): The recorded file, as returned by ExternalFiles.
+//
+// Returns:
+//   - error: nil if the file still belongs to the package, a *NotOwnedError if it does not, or the error from inspecting it, which satisfies os.IsNotExist if it is gone.
+func (p Package) CheckOwnership(cfg *Config, owned OwnedFile) error {
+	info, err := os.Lstat(owned.Path)
+	if err != nil {
+		return err
+	}
+	notOwned := func(reason string) error {
+		return &NotOwnedError{Path: owned.Path, Package: p.Name, Reason: reason}
+	}
+
+	if owned.Type == OwnedSymlink {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return notOwned("it has been replaced by a file that is not a symlink")
+		}
+		target, err := os.Readlink(owned.Path)
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(target) {
+			target = cfg.HostPath(target)
+		} else {
+			target = filepath.Join(filepath.Dir(owned.Path), target)
+		}
+		if !p.containsPath(target) {
+			return notOwned(fmt.Sprintf("it points to %s, outside the package", target))
+		}
+		return nil
+	}
+
+	if !info.Mode().IsRegular() {
+		return notOwned("it has been replaced by a file of another type")
+	}
+	if owned.SHA256 != "" {
+		if digest, err := HashFile(owned.Path); err == nil && digest == owned.SHA256 {
+			return nil
+		}
+	}
+	if filepath.Ext(owned.Path) == ".desktop" {
+		if executable := DesktopExecutable(owned.Path); executable != "" && p.containsPath(cfg.HostPath(executable)) {
+			return nil
+		}
+		return notOwned("it does not launch an executable from the package")
+	}
+	if owned.SHA256 == "" {
+		return notOwned("the package did not record creating it")
+	}
+	return notOwned("it has been modified since the package was installed")
+}
+
+// containsPath reports whether path lies inside one of the package's installation
+// directories.
+func (p Package) containsPath(path string) bool {
+	for _, installPath := range p.InstallPaths() {
+		if isWithin(installPath, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentFiles returns the recorded objects inside the package's installation directory.
+func (p Package) contentFiles() []OwnedFile {
+	var files []OwnedFile
+	for _, owned := range p.Files {
+		if isWithin(p.InstallPath, owned.Path) {
+			files = append(files, owned)
+		}
+	}
+	return files
+}
+
+// integrationPaths returns where the package's symlinks and .desktop entry live.
+func (p Package) integrationPaths(cfg *Config) []string {
+	var paths []string
+	for _, executable := range p.LinkedExecutables() {
+		paths = append(paths, p.SymlinkPath(cfg, executable))
+	}
+	if !p.NoDesktop {
+		paths = append(paths, DesktopFilePath(cfg, p.Name))
+	}
+	return paths
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Package represents an installed package with its essential metadata.
@@ -14,6 +15,97 @@ type Package struct {
 	Name        string `json:"name"`         // The user-friendly name of the package.
 	InstallPath string `json:"install_path"` // The filesystem path where the package is installed.
 	Executable  string `json:"executable"`   // The path to the package's main executable file.
+
+	Version     string    `json:"version,omitempty"` // The version of the packaged software, if known.
+	InstalledAt time.Time `json:"installed_at"`      // When this installation was made.
+
+	// Metadata below is populated from the package manifest, when the archive ships one.
+	Description string        `json:"description,omitempty"` // A one-line description of the package.
+	Executables []string      `json:"executables,omitempty"` // Every executable linked into the bin directory, including the main one.
+	Icon        string        `json:"icon,omitempty"`        // The path to the package's icon file.
+	Categories  []string      `json:"categories,omitempty"`  // Desktop menu categories.
+	Desktop     *DesktopEntry `json:"desktop,omitempty"`     // Additional .desktop entry metadata.
+
+	NoDesktop bool `json:"no_desktop,omitempty"` // Whether the package was installed without a .desktop file.
+
+	// The archive the package was installed from, so that the exact artifact is known.
+	Source         string `json:"source,omitempty"`          // The path or URL of the archive.
+	ArchiveDigest  string `json:"archive_digest,omitempty"`  // The checksum of the archive, e.g. "sha256:9f86d0...".
+	DigestVerified bool   `json:"digest_verified,omitempty"` // Whether the checksum matched one given by the user or published with the archive.
+	SignedBy       string `json:"signed_by,omitempty"`       // The ID of the trusted key that signed the archive.
+
+	// LinkNames maps an executable's file name to the name of its symlink in the bin
+	// directory, for executables that were linked under another name to avoid a conflict.
+	LinkNames map[string]string `json:"link_names,omitempty"`
+
+	// Files is the package's ownership manifest: every filesystem object it created, both
+	// inside its installation directory and outside it, such as symlinks and .desktop files.
+	Files []OwnedFile `json:"files,omitempty"`
+
+	// Generations holds previous installations of this package that are retained on disk
+	// so that the package can be rolled back. They never have generations of their own.
+	Generations []Package `json:"generations,omitempty"`
+}
+
+// LinkedExecutables returns every executable the package links into the bin directory.
+// Records created before manifests were supported only track the main executable.
+//
+// Returns:
+//   - []string: The absolute paths of the package's linked executables.
+func (p Package) LinkedExecutables() []string {
+	if len(p.Executables) > 0 {
+		return p.Executables
+	}
+	return []string{p.Executable}
+}
+
+// SymlinkPath returns where the symlink to one of the package's executables lives in the
+// bin directory. It is named after the executable unless the package was installed with
+// another name for it.
+//
+// Parameters:
+//   - cfg (*Config): The configuration giving the bin directory.
+//   - executable (string): The path of the executable.
+//
+// Returns:
+//   - string: The path of the symlink.
+func (p Package) SymlinkPath(cfg *Config, executable string) string {
+	name := filepath.Base(executable)
+	if linkName, ok := p.LinkNames[name]; ok {
+		name = linkName
+	}
+	return filepath.Join(cfg.BinDir, name)
+}
+
+// mapPaths returns a copy of the package with fn applied to every filesystem path it
+// records, including those of its generations.
+func (p Package) mapPaths(fn func(string) string) Package {
+	mapped := p
+	mapped.InstallPath = fn(p.InstallPath)
+	mapped.Executable = fn(p.Executable)
+	if p.Icon != "" {
+		mapped.Icon = fn(p.Icon)
+	}
+	if p.Executables != nil {
+		mapped.Executables = make([]string, len(p.Executables))
+		for i, executable := range p.Executables {
+			mapped.Executables[i] = fn(executable)
+		}
+	}
+	if p.Files != nil {
+		mapped.Files = make([]OwnedFile, len(p.Files))
+		for i, owned := range p.Files {
+			owned.Path = fn(owned.Path)
+			mapped.Files[i] = owned
+		}
+	}
+	if p.Generations != nil {
+		mapped.Generations = make([]Package, len(p.Generations))
+		for i, generation := range p.Generations {
+			mapped.Generations[i] = generation.mapPaths(fn)
+		}
+	}
+	return mapped
 }
 
 // PackageManager manages the collection of installed packages.
@@ -21,6 +113,11 @@ type Package struct {
 type PackageManager struct {
 	PackagesFile string    // The path to the JSON file that stores package metadata.
 	Packages     []Package // A slice containing all the currently installed packages.
+
+	// Root is the alternate root directory the database belongs to, or "". In memory,
+	// package paths are host paths beneath Root; on disk they are stored as seen from
+	// inside the root, so that the database is valid when the root is booted.
+	Root string
 }
 
 // NewPackageManager creates and initializes a new PackageManager.
@@ -33,62 +130,112 @@ type PackageManager struct {
 //   - *PackageManager: A pointer to the initialized PackageManager.
 //   - error: An error object if initialization fails, otherwise nil.
 func NewPackageManager(packagesFile string) (*PackageManager, error) {
+	return NewPackageManagerWithRoot(packagesFile, "")
+}
+
+// NewPackageManagerWithRoot creates a PackageManager for a database inside an alternate
+// root directory. Paths read from the database are placed under root, and are converted
+// back to paths inside the root when saving.
+//
+// Parameters:
+//   - packagesFile (string): The host path to the JSON file that stores package metadata.
+//   - root (string): The alternate root directory, or "" for none.
+//
+// Returns:
+//   - *PackageManager: A pointer to the initialized PackageManager.
+//   - error: An error object if initialization fails, otherwise nil.
+func NewPackageManagerWithRoot(packagesFile, root string) (*PackageManager, error) {
+	return NewPackageManagerWithLock(packagesFile, root, ExclusiveLock)
+}
+
+// NewPackageManagerWithLock creates a PackageManager for a database whose store the
+// caller has locked. Only the holder of an exclusive lock may change files: it creates a
+// missing packages file and restores a damaged one from the backup. Under a shared lock
+// the database is read as it is, using the backup's contents if the packages file is
+// damaged, so that concurrent readers never race each other or a writer.
+//
+// Parameters:
+//   - packagesFile (string): The host path to the JSON file that stores package metadata.
+//   - root (string): The alternate root directory, or "" for none.
+//   - mode (LockMode): The lock the caller holds on the store directory.
+//
+// Returns:
+//   - *PackageManager: A pointer to the initialized PackageManager.
+//   - error: An error object if initialization fails, otherwise nil.
+func NewPackageManagerWithLock(packagesFile, root string, mode LockMode) (*PackageManager, error) {
 	pm := &PackageManager{
 		PackagesFile: packagesFile,
 		Packages:     []Package{},
+		Root:         root,
 	}
+	writable := mode == ExclusiveLock
 
-	// Check if the packages file exists.
+	// Check if the packages file exists. If a backup survives, the database is recovered
+	// from it below; otherwise nothing has been installed yet.
 	if _, err := os.Stat(packagesFile); os.IsNotExist(err) {
-		// Create the packages directory if it doesn't exist.
-		packagesDir := filepath.Dir(packagesFile)
-		err := os.MkdirAll(packagesDir, 0755)
-		if err != nil {
-			return nil, fmt.Errorf("error creating packages directory: %v", err)
-		}
+		if _, err := os.Stat(BackupPath(packagesFile)); os.IsNotExist(err) {
+			if !writable {
+				return pm, nil
+			}
 
-		// Create an empty packages file.
-		file, err := os.Create(packagesFile)
-		if err != nil {
-			return nil, fmt.Errorf("error creating packages file: %v", err)
-		}
-		defer file.Close()
+			// Create the packages directory if it doesn't exist.
+			packagesDir := filepath.Dir(packagesFile)
+			err := os.MkdirAll(packagesDir, 0755)
+			if err != nil {
+				return nil, fmt.Errorf("error creating packages directory: %v", err)
+			}
 
-		// Initialize the packages file with an empty JSON array.
-		_, err = file.Write([]byte("[]"))
-		if err != nil {
-			return nil, fmt.Errorf("error initializing packages file: %v", err)
+			// Initialize the packages file with an empty JSON array.
+			if err := writeFileAtomic(packagesFile, []byte("[]"), 0644); err != nil {
+				return nil, fmt.Errorf("error initializing packages file: %v", err)
+			}
 		}
 	}
 
-	// Load existing packages from the packages file.
-	data, err := os.ReadFile(packagesFile)
+	// Load existing packages from the packages file, falling back to its backup if it is damaged.
+	packages, err := loadDatabase(packagesFile, writable)
 	if err != nil {
-		return nil, fmt.Errorf("error reading packages file: %v", err)
+		return nil, err
 	}
+	pm.Packages = packages
 
-	// Unmarshal the JSON data into the Packages slice.
-	if err := json.Unmarshal(data, &pm.Packages); err != nil {
-		return nil, fmt.Errorf("error unmarshalling packages file: %v", err)
+	if root != "" {
+		for i := range pm.Packages {
+			pm.Packages[i] = pm.Packages[i].mapPaths(func(path string) string {
+				return filepath.Join(root, path)
+			})
+		}
 	}
 
 	return pm, nil
 }
 
 // Save persists the current state of installed packages to the packages file.
-// It serializes the Packages slice into JSON format and writes it to the file.
+// It serializes the Packages slice into JSON format and atomically replaces the file,
+// keeping the previous version as a backup for recovery.
 //
 // Returns:
 //   - error: An error object if saving fails, otherwise nil.
 func (pm *PackageManager) Save() error {
+	// Record paths as seen from inside the alternate root, if there is one.
+	packages := pm.Packages
+	if pm.Root != "" {
+		packages = make([]Package, len(pm.Packages))
+		for i, p := range pm.Packages {
+			packages[i] = p.mapPaths(func(path string) string {
+				return stripRoot(pm.Root, path)
+			})
+		}
+	}
+
 	// Marshal the Packages slice into indented JSON for readability.
-	data, err := json.MarshalIndent(pm.Packages, "", "  ")
+	data, err := json.MarshalIndent(packages, "", "  ")
 	if err != nil {
 		return fmt.Errorf("error marshalling packages: %v", err)
 	}
 
-	// Write the JSON data to the packages file with appropriate permissions.
-	if err := os.WriteFile(pm.PackagesFile, data, 0644); err != nil {
+	// Write the JSON data to the packages file without ever leaving it half-written.
+	if err := writeDatabase(pm.PackagesFile, data); err != nil {
 		return fmt.Errorf("error writing packages file: %v", err)
 	}
 
@@ -135,3 +282,38 @@ func (pm *PackageManager) RemovePackage(uuid string) error {
 	pm.Packages = append(pm.Packages[:index], pm.Packages[index+1:]...)
 	return pm.Save()
 }
+
+// FindPackage looks up an installed package by its name.
+//
+// Parameters:
+//   - name (string): The name of the package to find.
+//
+// Returns:
+//   - *Package: A pointer to the package record within Packages, or nil if it is not installed.
+func (pm *PackageManager) FindPackage(name string) *Package {
+	for i := range pm.Packages {
+		if pm.Packages[i].Name == name {
+			return &pm.Packages[i]
+		}
+	}
+	return nil
+}
+
+// ReplacePackage swaps the record with the given UUID for a new one, keeping its
+// position in the Packages slice, and saves the updated list.
+//
+// Parameters:
+//   - uuid (string): The unique identifier of the package to be replaced.
+//   - pkg (Package): The new package record.
+//
+// Returns:
+//   - error: An error object if the package is not found or saving fails, otherwise nil.
+func (pm *PackageManager) ReplacePackage(uuid string, pkg Package) error {
+	for i := range pm.Packages {
+		if pm.Packages[i].UUID == uuid {
+			pm.Packages[i] = pkg
+			return pm.Save()
+		}
+	}
+	return fmt.Errorf("package with UUID %s not found", uuid)
+}
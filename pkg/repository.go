@@ -0,0 +1,314 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// IndexFileName is the name of the index file at the top of a repository.
+const IndexFileName = "index.json"
+
+// Repository is a directory or static HTTP(S) server that publishes package archives
+// along with an index describing them.
+type Repository struct {
+	Name  string    `json:"name"`  // The name the repository was added under.
+	URL   string    `json:"url"`   // The absolute directory or HTTP(S) URL holding IndexFileName.
+	Added time.Time `json:"added"` // When the repository was added.
+}
+
+// IndexURL returns the location of the repository's index file.
+//
+// Returns:
+//   - string: The path or URL of IndexFileName within the repository.
+func (r Repository) IndexURL() string {
+	return r.Resolve(IndexFileName)
+}
+
+// Resolve turns a location listed in the repository's index into a path or URL. Absolute
+// paths and URLs are returned unchanged; anything else is relative to the repository.
+//
+// Parameters:
+//   - location (string): A path or URL from the index.
+//
+// Returns:
+//   - string: The absolute path or URL.
+func (r Repository) Resolve(location string) string {
+	if IsURL(location) || filepath.IsAbs(location) {
+		return location
+	}
+	if !IsURL(r.URL) {
+		return filepath.Join(r.URL, filepath.FromSlash(location))
+	}
+	base, err := url.Parse(strings.TrimSuffix(r.URL, "/") + "/")
+	if err != nil {
+		return r.URL + "/" + location
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return r.URL + "/" + location
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// Index is the contents of a repository's index file.
+type Index struct {
+	Packages []IndexEntry `json:"packages"` // Every archive the repository publishes.
+}
+
+// IndexEntry describes one archive in a repository index.
+type IndexEntry struct {
+	Name        string `json:"name"`                  // The package name.
+	Version     string `json:"version,omitempty"`     // The package version.
+	Archive     string `json:"archive"`               // The archive's path or URL, relative to the repository.
+	Checksum    string `json:"checksum,omitempty"`    // The archive's checksum, e.g. "sha256:9f86d0...".
+	Description string `json:"description,omitempty"` // A one-line description of the package.
+}
+
+// ParseIndex reads and validates a repository index.
+//
+// Parameters:
+//   - data ([]byte): The contents of an index file.
+//
+// Returns:
+//   - *Index: The index.
+//   - error: An error object if the index is not valid JSON or an entry lacks a name or archive or has an invalid checksum, otherwise nil.
+func ParseIndex(data []byte) (*Index, error) {
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("error parsing index: %v", err)
+	}
+	for i, entry := range index.Packages {
+		if entry.Name == "" || entry.Archive == "" {
+			return nil, fmt.Errorf("index entry %d must have a name and an archive", i+1)
+		}
+		if entry.Checksum != "" {
+			if _, err := ParseDigest(entry.Checksum); err != nil {
+				return nil, fmt.Errorf("index entry for %s %s: %v", entry.Name, entry.Version, err)
+			}
+		}
+	}
+	return &index, nil
+}
+
+// RepositoryList is the set of configured repositories, stored as JSON in the store directory.
+type RepositoryList struct {
+	Path         string       // The repository list file.
+	Repositories []Repository // The repositories, in the order they were added, which is also their priority.
+}
+
+// LoadRepositories reads the repository list. A missing file is an empty list.
+//
+// Parameters:
+//   - listPath (string): The path to the repository list file.
+//
+// Returns:
+//   - *RepositoryList: The configured repositories.
+//   - error: An error object if the file exists but cannot be read or parsed, otherwise nil.
+func LoadRepositories(listPath string) (*RepositoryList, error) {
+	list := &RepositoryList{Path: listPath, Repositories: []Repository{}}
+	data, err := os.ReadFile(listPath)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading repository list %s: %v", listPath, err)
+	}
+	if err := json.Unmarshal(data, &list.Repositories); err != nil {
+		return nil, fmt.Errorf("error parsing repository list %s: %v", listPath, err)
+	}
+	return list, nil
+}
+
+// Save writes the repository list atomically.
+//
+// Returns:
+//   - error: An error object if the list cannot be written, otherwise nil.
+func (l *RepositoryList) Save() error {
+	data, err := json.MarshalIndent(l.Repositories, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding repository list: %v", err)
+	}
+	if err := writeFileAtomic(l.Path, data, 0644); err != nil {
+		return fmt.Errorf("error writing repository list %s: %v", l.Path, err)
+	}
+	return nil
+}
+
+// Find returns the repository with a name, or nil if there is none.
+//
+// Parameters:
+//   - name (string): The repository name.
+//
+// Returns:
+//   - *Repository: A pointer to the repository in the list, or nil.
+func (l *RepositoryList) Find(name string) *Repository {
+	for i := range l.Repositories {
+		if l.Repositories[i].Name == name {
+			return &l.Repositories[i]
+		}
+	}
+	return nil
+}
+
+// Remove removes the repository with a name from the list.
+//
+// Parameters:
+//   - name (string): The repository name.
+//
+// Returns:
+//   - bool: True if the repository was in the list.
+func (l *RepositoryList) Remove(name string) bool {
+	for i := range l.Repositories {
+		if l.Repositories[i].Name == name {
+			l.Repositories = append(l.Repositories[:i], l.Repositories[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IndexCachePath returns where the last fetched index of a repository is kept.
+//
+// Parameters:
+//   - cfg (*Config): The configuration of the scope.
+//   - name (string): The repository name.
+//
+// Returns:
+//   - string: The path of the cached index within the cache directory.
+func IndexCachePath(cfg *Config, name string) string {
+	return filepath.Join(cfg.CacheDir, "indexes", name+".json")
+}
+
+// UpdateIndex fetches a repository's index, validates it and replaces the cached copy.
+//
+// Parameters:
+//   - cfg (*Config): The configuration of the scope.
+//   - repo (Repository): The repository to update.
+//   - timeout (time.Duration): How long to wait for a remote index.
+//
+// Returns:
+//   - *Index: The fetched index.
+//   - error: An error object if the index cannot be fetched, is invalid or cannot be cached, otherwise nil.
+func UpdateIndex(cfg *Config, repo Repository, timeout time.Duration) (*Index, error) {
+	location := repo.IndexURL()
+	var data []byte
+	var err error
+	if IsURL(location) {
+		data, err = fetchSmallFile(location, timeout)
+		if err == nil && data == nil {
+			err = fmt.Errorf("not found")
+		}
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", location, err)
+	}
+
+	index, err := ParseIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", location, err)
+	}
+
+	cachePath := IndexCachePath(cfg, repo.Name)
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return nil, fmt.Errorf("error creating index cache: %v", err)
+	}
+	if err := writeFileAtomic(cachePath, data, 0644); err != nil {
+		return nil, fmt.Errorf("error caching index of %s: %v", repo.Name, err)
+	}
+	return index, nil
+}
+
+// LoadIndex reads the cached index of a repository, as last fetched by UpdateIndex.
+//
+// Parameters:
+//   - cfg (*Config): The configuration of the scope.
+//   - repo (Repository): The repository.
+//
+// Returns:
+//   - *Index: The cached index.
+//   - error: An error object if the index has not been fetched yet or cannot be read, otherwise nil.
+func LoadIndex(cfg *Config, repo Repository) (*Index, error) {
+	data, err := os.ReadFile(IndexCachePath(cfg, repo.Name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("the index of %s has not been fetched", repo.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the index of %s: %v", repo.Name, err)
+	}
+	return ParseIndex(data)
+}
+
+// ParsePackageReference splits a reference such as "mytool@1.2.0" into the package name
+// and version. The version is empty when the reference has none.
+//
+// Parameters:
+//   - ref (string): The package name, optionally followed by "@" and a version.
+//
+// Returns:
+//   - string: The package name.
+//   - string: The requested version, or "".
+func ParsePackageReference(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "@"); i > 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// ResolvePackage finds a package in the cached indexes of the configured repositories.
+// Without a version, the newest version is chosen; when several repositories publish the
+// same version, the one added first wins.
+//
+// Parameters:
+//   - cfg (*Config): The configuration of the scope.
+//   - repos (*RepositoryList): The configured repositories.
+//   - name (string): The package name.
+//   - version (string): The version to install, or "" for the newest.
+//
+// Returns:
+//   - IndexEntry: The matching index entry.
+//   - Repository: The repository that publishes it.
+//   - error: An error object if no fetched index lists the package or version, otherwise nil.
+func ResolvePackage(cfg *Config, repos *RepositoryList, name, version string) (IndexEntry, Repository, error) {
+	if len(repos.Repositories) == 0 {
+		return IndexEntry{}, Repository{}, fmt.Errorf("no repositories are configured")
+	}
+
+	var best IndexEntry
+	var bestRepo Repository
+	found, fetched := false, false
+	for _, repo := range repos.Repositories {
+		index, err := LoadIndex(cfg, repo)
+		if err != nil {
+			continue
+		}
+		fetched = true
+		for _, entry := range index.Packages {
+			if entry.Name != name {
+				continue
+			}
+			if version != "" && CompareVersions(entry.Version, version) != 0 {
+				continue
+			}
+			if !found || CompareVersions(entry.Version, best.Version) > 0 {
+				best, bestRepo, found = entry, repo, true
+			}
+		}
+	}
+
+	switch {
+	case found:
+		return best, bestRepo, nil
+	case !fetched:
+		return IndexEntry{}, Repository{}, fmt.Errorf("no repository indexes have been fetched (run update to fetch them)")
+	case version != "":
+		return IndexEntry{}, Repository{}, fmt.Errorf("no repository has %s version %s", name, version)
+	}
+	return IndexEntry{}, Repository{}, fmt.Errorf("no repository has a package named %s", name)
+}
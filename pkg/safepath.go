@@ -0,0 +1,167 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnsafePathError is returned when an archive entry would be written, or would link,
+// outside of the destination directory. Extraction is aborted when it is encountered.
+type UnsafePathError struct {
+	Entry  string // The name of the offending entry as recorded in the archive.
+	Target string // The path or link target that escapes the destination directory.
+	Reason string // A short description of why the entry was rejected.
+}
+
+// Error implements the error interface.
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("unsafe archive entry %q: %s (%s)", e.Entry, e.Reason, e.Target)
+}
+
+// isWithin reports whether path is destDir itself or lies beneath it.
+// Both arguments are expected to be cleaned, absolute or equally relative paths.
+func isWithin(destDir, path string) bool {
+	rel, err := filepath.Rel(destDir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// secureJoin resolves an archive entry name against destDir and guarantees that the
+// result stays inside destDir. Absolute names and names containing ".." components
+// that climb out of destDir are rejected, as are paths that would be written through
+// a symlink already present inside destDir.
+//
+// Parameters:
+//   - destDir (string): The destination directory of the extraction.
+//   - name (string): The entry name as recorded in the archive.
+//
+// Returns:
+//   - string: The cleaned path the entry should be written to.
+//   - error: An *UnsafePathError if the entry escapes destDir, otherwise nil.
+func secureJoin(destDir, name string) (string, error) {
+	// Archives always use forward slashes; normalise before inspecting the name.
+	cleanName := filepath.FromSlash(name)
+
+	if filepath.IsAbs(cleanName) || filepath.VolumeName(cleanName) != "" {
+		return "", &UnsafePathError{Entry: name, Target: cleanName, Reason: "absolute path"}
+	}
+
+	cleanDest := filepath.Clean(destDir)
+	targetPath := filepath.Join(cleanDest, cleanName)
+	if !isWithin(cleanDest, targetPath) {
+		return "", &UnsafePathError{Entry: name, Target: targetPath, Reason: "path escapes destination directory"}
+	}
+
+	// Refuse to write through a symlink created by an earlier entry, which could
+	// otherwise redirect this entry to an arbitrary location on the filesystem.
+	if err := checkNoSymlinkParents(cleanDest, targetPath, name); err != nil {
+		return "", err
+	}
+
+	return targetPath, nil
+}
+
+// checkNoSymlinkParents walks each parent directory of targetPath below destDir and
+// rejects the entry if any of them is a symlink.
+func checkNoSymlinkParents(destDir, targetPath, entry string) error {
+	rel, err := filepath.Rel(destDir, filepath.Dir(targetPath))
+	if err != nil || rel == "." {
+		return nil
+	}
+
+	current := destDir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			// Nothing further down can exist yet, so there are no symlinks to follow.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error inspecting %s: %v", current, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return &UnsafePathError{Entry: entry, Target: current, Reason: "path traverses a symlink"}
+		}
+	}
+
+	return nil
+}
+
+// validateLinkTarget checks that a symlink or hardlink created at linkPath and pointing
+// at linkTarget resolves to a location inside destDir.
+//
+// A lexical check is not enough once the archive has created symlinks of its own: with
+// "a/b -> ..", the target "a/b/../../x" looks as if it stays inside destDir but climbs
+// out of it on disk. A ".." in the target is therefore only allowed to step back out of
+// a directory that already exists as a real directory. Stepping back out of a symlink,
+// or out of a path that does not exist yet and may later be created as one, is rejected,
+// since where it leads would depend on symlinks that later entries may redefine.
+//
+// Parameters:
+//   - destDir (string): The destination directory of the extraction.
+//   - entry (string): The entry name as recorded in the archive, used in error messages.
+//   - linkPath (string): The path at which the link will be created.
+//   - linkTarget (string): The link target as recorded in the archive.
+//   - hardlink (bool): Whether the target is relative to destDir (hardlinks) rather than to the link's directory (symlinks).
+//
+// Returns:
+//   - error: An *UnsafePathError if the link would escape destDir, otherwise nil.
+func validateLinkTarget(destDir, entry, linkPath, linkTarget string, hardlink bool) error {
+	target := filepath.FromSlash(linkTarget)
+	if filepath.IsAbs(target) {
+		return &UnsafePathError{Entry: entry, Target: linkTarget, Reason: "link target is absolute"}
+	}
+
+	cleanDest := filepath.Clean(destDir)
+
+	// Symlink targets are resolved relative to the directory containing the link,
+	// whereas tar hardlink targets name another entry relative to the archive root.
+	base := filepath.Dir(linkPath)
+	if hardlink {
+		base = cleanDest
+	}
+
+	resolved := filepath.Join(base, target)
+	if !isWithin(cleanDest, resolved) {
+		return &UnsafePathError{Entry: entry, Target: linkTarget, Reason: "link target escapes destination directory"}
+	}
+
+	// Walk the target one component at a time, remembering for each component below base
+	// whether it is a real directory. The directories leading to base are real: secureJoin
+	// refuses symlinks among them and any that are missing are created as directories.
+	current := base
+	var real []bool
+	for _, part := range strings.Split(target, string(filepath.Separator)) {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if n := len(real); n > 0 {
+				if !real[n-1] {
+					return &UnsafePathError{Entry: entry, Target: linkTarget, Reason: "link target climbs out of a symlink or a path that does not exist yet"}
+				}
+				real = real[:n-1]
+			}
+			current = filepath.Dir(current)
+			if !isWithin(cleanDest, current) {
+				return &UnsafePathError{Entry: entry, Target: linkTarget, Reason: "link target escapes destination directory"}
+			}
+		default:
+			current = filepath.Join(current, part)
+			// Below a symlink, Lstat would describe wherever the symlink currently points.
+			isDir := len(real) == 0 || real[len(real)-1]
+			if isDir {
+				info, err := os.Lstat(current)
+				isDir = err == nil && info.IsDir()
+			}
+			real = append(real, isDir)
+		}
+	}
+
+	return nil
+}
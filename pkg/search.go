@@ -0,0 +1,199 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxReadmeScan is how much of a README is read when looking for its title.
+const maxReadmeScan = 64 << 10
+
+// ArchiveContents summarises what an archive would install, read without extracting it.
+type ArchiveContents struct {
+	Executables []string // The file names of the executables in the archive, sorted.
+	ReadmeTitle string   // The title of the top-level README, or "" if there is none.
+}
+
+// InspectArchive lists the executables in an archive and reads the title of its
+// top-level README, which may sit inside a single wrapping directory.
+//
+// Parameters:
+//   - archivePath (string): The path of the archive.
+//
+// Returns:
+//   - *ArchiveContents: What the archive contains.
+//   - error: An error object if the archive cannot be read or is not a supported format, otherwise nil.
+func InspectArchive(archivePath string) (*ArchiveContents, error) {
+	format, err := DetectArchiveFormat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := &ArchiveContents{}
+	executables := map[string]bool{}
+	readmeDepth := -1
+
+	// visit records one entry; open returns its contents and is only called for a README.
+	visit := func(name string, mode fs.FileMode, open func() (io.ReadCloser, error)) error {
+		name = strings.Trim(path.Clean("/"+name), "/")
+		if !mode.IsRegular() || name == "" {
+			return nil
+		}
+		if mode&0111 != 0 {
+			executables[path.Base(name)] = true
+		}
+
+		depth := strings.Count(name, "/")
+		if depth <= 1 && isReadme(name) && (readmeDepth < 0 || depth < readmeDepth) {
+			r, err := open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			contents.ReadmeTitle = readmeTitle(r)
+			readmeDepth = depth
+		}
+		return nil
+	}
+
+	switch format {
+	case FormatZip:
+		zipReader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("error opening zip archive: %v", err)
+		}
+		defer zipReader.Close()
+
+		for _, f := range zipReader.File {
+			if err := visit(f.Name, zipFileMode(f), f.Open); err != nil {
+				return nil, fmt.Errorf("error reading zip entry %s: %v", f.Name, err)
+			}
+		}
+
+	case FormatTar:
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("error opening archive: %v", err)
+		}
+		defer file.Close()
+
+		decompressed, _, err := NewDecompressingReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer decompressed.Close()
+
+		tarReader := tar.NewReader(decompressed)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading tar archive: %v", err)
+			}
+			open := func() (io.ReadCloser, error) { return io.NopCloser(tarReader), nil }
+			if err := visit(header.Name, header.FileInfo().Mode(), open); err != nil {
+				return nil, fmt.Errorf("error reading tar entry %s: %v", header.Name, err)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", archivePath)
+	}
+
+	for name := range executables {
+		contents.Executables = append(contents.Executables, name)
+	}
+	sort.Strings(contents.Executables)
+	return contents, nil
+}
+
+// ReadmeTitle returns the title of the README at the top of an installed package, or
+// inside its single top-level directory, or "" if it has none.
+//
+// Parameters:
+//   - installPath (string): The package's installation directory.
+//
+// Returns:
+//   - string: The README's title.
+func ReadmeTitle(installPath string) string {
+	for _, root := range manifestRoots(installPath) {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !isReadme(entry.Name()) {
+				continue
+			}
+			file, err := os.Open(filepath.Join(root, entry.Name()))
+			if err != nil {
+				continue
+			}
+			title := readmeTitle(file)
+			file.Close()
+			return title
+		}
+	}
+	return ""
+}
+
+// DiskUsage adds up the sizes of the regular files beneath a directory. Symlinks are
+// not followed.
+//
+// Parameters:
+//   - dir (string): The directory to measure.
+//
+// Returns:
+//   - int64: The total size in bytes.
+//   - error: An error object if the directory cannot be walked, otherwise nil.
+func DiskUsage(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// isReadme reports whether a file name is a README, such as README, README.md or readme.txt.
+func isReadme(name string) bool {
+	base := path.Base(name)
+	return strings.EqualFold(strings.TrimSuffix(base, path.Ext(base)), "readme")
+}
+
+// readmeTitle returns the first line of a README that reads as a title, without
+// Markdown heading markers, skipping blank lines, HTML and badge images.
+func readmeTitle(r io.Reader) string {
+	scanner := bufio.NewScanner(io.LimitReader(r, maxReadmeScan))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "<") || strings.HasPrefix(line, "![") || strings.HasPrefix(line, "[![") {
+			continue
+		}
+		title := strings.TrimSpace(strings.Trim(line, "#"))
+		if title != "" && strings.Trim(title, "=-") != "" {
+			return title
+		}
+	}
+	return ""
+}
@@ -0,0 +1,288 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"aead.dev/minisign"
+)
+
+// Signature policies, chosen with the signature_policy configuration key.
+const (
+	// SignaturesOptional verifies a signature published with an archive, but installs
+	// unsigned archives and archives signed by keys not trusted for the package with a warning.
+	SignaturesOptional = "optional"
+	// SignaturesRequired refuses archives that are not signed by a key trusted for the package.
+	SignaturesRequired = "required"
+)
+
+// SignatureExtension is appended to an archive's path or URL to find its detached minisign signature.
+const SignatureExtension = ".minisig"
+
+// TrustedKey is a publisher's minisign public key in the keyring.
+type TrustedKey struct {
+	ID        string    `json:"id"`                 // The key ID, as printed by minisign.
+	PublicKey string    `json:"public_key"`         // The base64-encoded public key.
+	Comment   string    `json:"comment,omitempty"`  // A note on whose key this is.
+	Packages  []string  `json:"packages,omitempty"` // Package name patterns the key may sign; empty means any package.
+	Added     time.Time `json:"added"`              // When the key was added.
+}
+
+// TrustedFor reports whether the key may sign a package. Patterns use path.Match syntax,
+// so "mytool-*" matches every package whose name starts with "mytool-".
+//
+// Parameters:
+//   - name (string): The name of the package.
+//
+// Returns:
+//   - bool: True if the key has no package restrictions or one of its patterns matches name.
+func (k TrustedKey) TrustedFor(name string) bool {
+	if len(k.Packages) == 0 {
+		return true
+	}
+	for _, pattern := range k.Packages {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope describes the packages a key may sign, for messages and listings.
+//
+// Returns:
+//   - string: "any package" or the comma-separated package patterns.
+func (k TrustedKey) Scope() string {
+	if len(k.Packages) == 0 {
+		return "any package"
+	}
+	return strings.Join(k.Packages, ", ")
+}
+
+// ParsePublicKey reads a minisign public key, either the contents of a minisign .pub
+// file or the bare base64-encoded key.
+//
+// Parameters:
+//   - text ([]byte): The public key.
+//
+// Returns:
+//   - TrustedKey: The key, with its ID and public key filled in.
+//   - error: An error object if text is not a minisign public key, otherwise nil.
+func ParsePublicKey(text []byte) (TrustedKey, error) {
+	var key minisign.PublicKey
+	if err := key.UnmarshalText([]byte(strings.TrimSpace(string(text)))); err != nil {
+		return TrustedKey{}, err
+	}
+	return TrustedKey{ID: formatKeyID(key.ID()), PublicKey: key.String()}, nil
+}
+
+// formatKeyID renders a key ID the way minisign prints it.
+func formatKeyID(id uint64) string {
+	return fmt.Sprintf("%016X", id)
+}
+
+// Keyring is the set of publisher keys trusted to sign archives, stored as JSON in the
+// store directory.
+type Keyring struct {
+	Path string       // The keyring file.
+	Keys []TrustedKey // The trusted keys, in the order they were added.
+}
+
+// LoadKeyring reads a keyring. A missing file is an empty keyring.
+//
+// Parameters:
+//   - keyringPath (string): The path to the keyring file.
+//
+// Returns:
+//   - *Keyring: The keyring.
+//   - error: An error object if the file exists but cannot be read or parsed, otherwise nil.
+func LoadKeyring(keyringPath string) (*Keyring, error) {
+	keyring := &Keyring{Path: keyringPath, Keys: []TrustedKey{}}
+	data, err := os.ReadFile(keyringPath)
+	if os.IsNotExist(err) {
+		return keyring, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading keyring %s: %v", keyringPath, err)
+	}
+	if err := json.Unmarshal(data, &keyring.Keys); err != nil {
+		return nil, fmt.Errorf("error parsing keyring %s: %v", keyringPath, err)
+	}
+	return keyring, nil
+}
+
+// Save writes the keyring atomically.
+//
+// Returns:
+//   - error: An error object if the keyring cannot be written, otherwise nil.
+func (k *Keyring) Save() error {
+	data, err := json.MarshalIndent(k.Keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding keyring: %v", err)
+	}
+	if err := writeFileAtomic(k.Path, data, 0644); err != nil {
+		return fmt.Errorf("error writing keyring %s: %v", k.Path, err)
+	}
+	return nil
+}
+
+// Find returns the key with an ID, ignoring case, or nil if it is not in the keyring.
+//
+// Parameters:
+//   - id (string): The key ID.
+//
+// Returns:
+//   - *TrustedKey: A pointer to the key in the keyring, or nil.
+func (k *Keyring) Find(id string) *TrustedKey {
+	for i := range k.Keys {
+		if strings.EqualFold(k.Keys[i].ID, id) {
+			return &k.Keys[i]
+		}
+	}
+	return nil
+}
+
+// Add adds a key to the keyring, replacing the entry for the same key if there is one
+// so that its comment and package patterns can be changed.
+//
+// Parameters:
+//   - key (TrustedKey): The key to trust.
+//
+// Returns:
+//   - bool: True if the key replaced an existing entry.
+func (k *Keyring) Add(key TrustedKey) bool {
+	if existing := k.Find(key.ID); existing != nil {
+		*existing = key
+		return true
+	}
+	k.Keys = append(k.Keys, key)
+	return false
+}
+
+// Remove removes the key with an ID from the keyring.
+//
+// Parameters:
+//   - id (string): The key ID, ignoring case.
+//
+// Returns:
+//   - bool: True if the key was in the keyring.
+func (k *Keyring) Remove(id string) bool {
+	for i := range k.Keys {
+		if strings.EqualFold(k.Keys[i].ID, id) {
+			k.Keys = append(k.Keys[:i], k.Keys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownKeyError reports that an archive is signed by a key that is not in the keyring.
+type UnknownKeyError struct {
+	KeyID string // The ID of the signing key.
+}
+
+func (e *UnknownKeyError) Error() string {
+	return fmt.Sprintf("the archive is signed by key %s, which is not in the keyring", e.KeyID)
+}
+
+// FindSignature looks for the detached minisign signature of an archive, at the path or
+// URL of the archive with SignatureExtension appended, or at an explicit location.
+//
+// Parameters:
+//   - source (string): The archive path or URL given by the user.
+//   - location (string): The path or URL of the signature, or "" to look next to the archive.
+//   - timeout (time.Duration): How long to wait for a remote signature.
+//
+// Returns:
+//   - []byte: The signature, or nil if there is none next to the archive.
+//   - string: Where the signature was looked for.
+//   - error: An error object if the signature cannot be read, or an explicit location does not exist, otherwise nil.
+//     If a server would not say whether the signature exists, the error is an *UnavailableError.
+func FindSignature(source, location string, timeout time.Duration) ([]byte, string, error) {
+	explicit := location != ""
+	if !explicit {
+		location = source + SignatureExtension
+	}
+
+	var data []byte
+	var err error
+	if IsURL(location) {
+		data, err = fetchSmallFile(location, timeout)
+	} else {
+		data, err = os.ReadFile(location)
+		if os.IsNotExist(err) && !explicit {
+			data, err = nil, nil
+		}
+	}
+	var unavailable *UnavailableError
+	if errors.As(err, &unavailable) {
+		return nil, location, err
+	}
+	if err != nil {
+		return nil, location, fmt.Errorf("error reading signature %s: %v", location, err)
+	}
+	if data == nil && explicit {
+		return nil, location, fmt.Errorf("signature %s does not exist", location)
+	}
+	return data, location, nil
+}
+
+// VerifySignature checks a detached minisign signature of an archive against the keyring.
+// Both plain and pre-hashed signatures are supported; pre-hashed ones, minisign's default,
+// are verified without reading the whole archive into memory.
+//
+// Parameters:
+//   - archivePath (string): The path of the archive.
+//   - signature ([]byte): The contents of the signature file.
+//   - keyring (*Keyring): The trusted keys.
+//
+// Returns:
+//   - *TrustedKey: The key that made the signature.
+//   - error: An *UnknownKeyError if the signing key is not in the keyring, or an error object if the signature is malformed or does not match the archive, otherwise nil.
+func VerifySignature(archivePath string, signature []byte, keyring *Keyring) (*TrustedKey, error) {
+	var sig minisign.Signature
+	if err := sig.UnmarshalText(signature); err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+
+	keyID := formatKeyID(sig.KeyID)
+	trusted := keyring.Find(keyID)
+	if trusted == nil {
+		return nil, &UnknownKeyError{KeyID: keyID}
+	}
+	var publicKey minisign.PublicKey
+	if err := publicKey.UnmarshalText([]byte(trusted.PublicKey)); err != nil {
+		return nil, fmt.Errorf("invalid key %s in keyring: %v", keyID, err)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var valid bool
+	if sig.Algorithm == minisign.HashEdDSA {
+		reader := minisign.NewReader(file)
+		if _, err := io.Copy(io.Discard, reader); err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", archivePath, err)
+		}
+		valid = reader.Verify(publicKey, signature)
+	} else {
+		message, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", archivePath, err)
+		}
+		valid = minisign.Verify(publicKey, message, signature)
+	}
+	if !valid {
+		return nil, fmt.Errorf("the signature by key %s does not match the archive", keyID)
+	}
+	return trusted, nil
+}
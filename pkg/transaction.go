@@ -0,0 +1,256 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// errTransactionFinished is returned by operations attempted after a transaction has
+// been committed or rolled back, for example while an interrupt is being handled.
+var errTransactionFinished = errors.New("transaction already finished")
+
+// Transaction records the side effects of a multi-step operation such as an install or
+// uninstall, so that a failure at any step, or an interrupt, undoes every change made so
+// far in reverse order. Removals are staged by moving the removed path aside; it is only
+// deleted when the transaction commits, so that a rollback can put it back.
+type Transaction struct {
+	mu       sync.Mutex
+	undo     []transactionStep // Undo actions, run in reverse order on rollback.
+	commit   []transactionStep // Actions deferred until the transaction commits.
+	finished bool
+	signals  chan os.Signal
+}
+
+// transactionStep is a single recorded action and a description used in messages.
+type transactionStep struct {
+	description string
+	fn          func() error
+}
+
+// NewTransaction starts an empty transaction.
+//
+// Returns:
+//   - *Transaction: The new transaction.
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// HandleSignals rolls the transaction back if the process receives SIGINT or SIGTERM
+// before it finishes, then exits with the conventional status for the signal. Signal
+// handling stops when the transaction is committed or rolled back.
+func (t *Transaction) HandleSignals() {
+	t.signals = make(chan os.Signal, 1)
+	signal.Notify(t.signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig, ok := <-t.signals
+		if !ok {
+			return
+		}
+		fmt.Printf("\nReceived %v; undoing changes...\n", sig)
+		t.Rollback()
+
+		code := 130
+		if sig == syscall.SIGTERM {
+			code = 143
+		}
+		os.Exit(code)
+	}()
+}
+
+// OnRollback records an action that undoes a change made outside the transaction. It
+// should be recorded before the change is started, so that an interrupt part-way
+// through the change is also undone.
+//
+// Parameters:
+//   - description (string): What the action undoes, for error messages.
+//   - fn (func() error): The undo action.
+func (t *Transaction) OnRollback(description string, fn func() error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.undo = append(t.undo, transactionStep{description: description, fn: fn})
+}
+
+// OnCommit records an action deferred until the transaction commits, such as deleting
+// files that must survive until the operation can no longer be rolled back.
+//
+// Parameters:
+//   - description (string): What the action does, for warnings.
+//   - fn (func() error): The commit action.
+func (t *Transaction) OnCommit(description string, fn func() error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.commit = append(t.commit, transactionStep{description: description, fn: fn})
+}
+
+// Do performs a change and, if it succeeds, records how to undo it. The change runs
+// while holding the transaction, so an interrupt never observes it half-recorded.
+//
+// Parameters:
+//   - description (string): What the change does, for error messages.
+//   - do (func() error): The change.
+//   - undo (func() error): The action that reverses the change.
+//
+// Returns:
+//   - error: The error returned by do, otherwise nil.
+func (t *Transaction) Do(description string, do func() error, undo func() error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return errTransactionFinished
+	}
+	if err := do(); err != nil {
+		return err
+	}
+	t.undo = append(t.undo, transactionStep{description: description, fn: undo})
+	return nil
+}
+
+// Preserve records the current state of a file or symlink so that a rollback restores
+// it, or removes it again if it does not exist yet. Call it before modifying the path.
+//
+// Parameters:
+//   - path (string): The file or symlink about to be created, replaced or modified.
+//
+// Returns:
+//   - error: An error object if the current state cannot be read, otherwise nil.
+func (t *Transaction) Preserve(path string) error {
+	var restore func() error
+
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		restore = func() error {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+	case err != nil:
+		return fmt.Errorf("error reading %s: %v", path, err)
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("error reading symlink %s: %v", path, err)
+		}
+		restore = func() error {
+			return ReplaceSymlink(target, path)
+		}
+	case info.Mode().IsRegular():
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		perm := info.Mode().Perm()
+		restore = func() error {
+			return writeFileAtomic(path, data, perm)
+		}
+	default:
+		return fmt.Errorf("%s is not a file or symlink", path)
+	}
+
+	return t.Do("restore "+path, func() error { return nil }, restore)
+}
+
+// Symlink creates a symlink that is removed again on rollback.
+//
+// Parameters:
+//   - target (string): The path the symlink points to.
+//   - linkPath (string): The path of the symlink.
+//
+// Returns:
+//   - error: An error object if the symlink cannot be created, otherwise nil.
+func (t *Transaction) Symlink(target, linkPath string) error {
+	return t.Do("create symlink "+linkPath, func() error {
+		return os.Symlink(target, linkPath)
+	}, func() error {
+		return os.Remove(linkPath)
+	})
+}
+
+// Remove stages the removal of a file, symlink or directory tree. The path is renamed
+// aside immediately, renamed back on rollback, and deleted when the transaction commits.
+//
+// Parameters:
+//   - path (string): The path to remove.
+//
+// Returns:
+//   - error: An error object if the path cannot be moved aside; os.IsNotExist reports a missing path.
+func (t *Transaction) Remove(path string) error {
+	t.mu.Lock()
+	aside := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.removed-%d-%d", filepath.Base(path), os.Getpid(), len(t.undo)))
+	t.mu.Unlock()
+
+	err := t.Do("remove "+path, func() error {
+		return os.Rename(path, aside)
+	}, func() error {
+		return os.Rename(aside, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	t.OnCommit("delete "+path, func() error {
+		return os.RemoveAll(aside)
+	})
+	return nil
+}
+
+// Commit finishes the transaction: its changes become permanent and the deferred commit
+// actions run. Failures of commit actions are reported as warnings, since the operation
+// itself has already succeeded.
+func (t *Transaction) Commit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return
+	}
+	t.finish()
+
+	for _, step := range t.commit {
+		if err := step.fn(); err != nil {
+			fmt.Printf("Warning: failed to %s: %v\n", step.description, err)
+		}
+	}
+}
+
+// Rollback undoes every recorded change in reverse order. Undo actions that fail are
+// reported and the remaining ones still run.
+//
+// Returns:
+//   - error: An error object if any change could not be undone, otherwise nil.
+func (t *Transaction) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return nil
+	}
+	t.finish()
+
+	failed := 0
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		step := t.undo[i]
+		if err := step.fn(); err != nil {
+			fmt.Printf("Warning: failed to undo %s: %v\n", step.description, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d change(s) could not be undone", failed)
+	}
+	return nil
+}
+
+// finish marks the transaction as finished and stops handling signals. The caller holds t.mu.
+func (t *Transaction) finish() {
+	t.finished = true
+	if t.signals != nil {
+		signal.Stop(t.signals)
+		close(t.signals)
+	}
+}
@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+)
+
+// FileProblem describes how a file owned by a package differs from what was recorded
+// when the package was installed.
+type FileProblem struct {
+	Path   string // The path of the file.
+	Reason string // What is wrong, e.g. "missing" or "contents changed".
+}
+
+// VerifyPackage compares the files a package owns with its ownership manifest: files
+// must still exist with the recorded type, permissions, size and SHA-256 digest, and
+// symlinks must point where they did. Records created before ownership manifests were
+// introduced are checked more loosely, by making sure the installation directory,
+// executables, symlinks and .desktop entry exist.
+//
+// Parameters:
+//   - p (Package): The package to verify.
+//   - cfg (*Config): The configuration giving the bin and desktop directories.
+//
+// Returns:
+//   - []FileProblem: Every difference found, or nil if the package is intact.
+//   - int: The number of files checked.
+func VerifyPackage(p Package, cfg *Config) ([]FileProblem, int) {
+	if len(p.Files) == 0 {
+		return verifyLegacyPackage(p, cfg)
+	}
+
+	var problems []FileProblem
+	for _, expected := range p.Files {
+		if reason := verifyFile(expected); reason != "" {
+			problems = append(problems, FileProblem{Path: expected.Path, Reason: reason})
+		}
+	}
+	return problems, len(p.Files)
+}
+
+// verifyFile checks a single owned file, returning what is wrong with it or "".
+func verifyFile(expected OwnedFile) string {
+	info, err := os.Lstat(expected.Path)
+	if os.IsNotExist(err) {
+		return "missing"
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	actualType := OwnedRegularFile
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		actualType = OwnedSymlink
+	case info.IsDir():
+		actualType = OwnedDirectory
+	case !info.Mode().IsRegular():
+		actualType = "special file"
+	}
+	if actualType != expected.Type {
+		return fmt.Sprintf("expected a %s, found a %s", expected.Type, actualType)
+	}
+
+	switch expected.Type {
+	case OwnedSymlink:
+		target, err := os.Readlink(expected.Path)
+		if err != nil {
+			return err.Error()
+		}
+		if target != expected.Target {
+			return fmt.Sprintf("points to %s instead of %s", target, expected.Target)
+		}
+		return ""
+	case OwnedRegularFile:
+		if info.Size() != expected.Size {
+			return fmt.Sprintf("size changed from %d to %d bytes", expected.Size, info.Size())
+		}
+		digest, err := HashFile(expected.Path)
+		if err != nil {
+			return err.Error()
+		}
+		if digest != expected.SHA256 {
+			return "contents changed (SHA-256 mismatch)"
+		}
+	}
+
+	if info.Mode().Perm() != expected.Mode {
+		return fmt.Sprintf("permissions changed from %v to %v", expected.Mode, info.Mode().Perm())
+	}
+	return ""
+}
+
+// verifyLegacyPackage checks a record without an ownership manifest.
+func verifyLegacyPackage(p Package, cfg *Config) ([]FileProblem, int) {
+	var problems []FileProblem
+	checked := 0
+	missing := func(path string) bool {
+		checked++
+		if _, err := os.Lstat(path); err != nil {
+			problems = append(problems, FileProblem{Path: path, Reason: "missing"})
+			return true
+		}
+		return false
+	}
+
+	missing(p.InstallPath)
+	for _, executable := range p.LinkedExecutables() {
+		missing(executable)
+
+		symlinkPath := p.SymlinkPath(cfg, executable)
+		if missing(symlinkPath) {
+			continue
+		}
+		if target, err := os.Readlink(symlinkPath); err != nil || target != cfg.ImagePath(executable) {
+			problems = append(problems, FileProblem{Path: symlinkPath, Reason: fmt.Sprintf("does not point to %s", cfg.ImagePath(executable))})
+		}
+	}
+	if !p.NoDesktop {
+		missing(DesktopFilePath(cfg, p.Name))
+	}
+	return problems, checked
+}
@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// archiveVersionPattern splits names such as "mytool-1.2.3" or "mytool_v2.0-linux-x64"
+// into the tool name and the version that follows it.
+var archiveVersionPattern = regexp.MustCompile(`^(.+?)[-_]v?(\d+(?:\.\d+)+[0-9A-Za-z.+~]*)`)
+
+// SplitNameVersion separates a version number from a name derived from an archive filename.
+//
+// Parameters:
+//   - name (string): A name such as "mytool-1.2.3", with archive extensions already removed.
+//
+// Returns:
+//   - string: The name without the version, or the input unchanged if no version was found.
+//   - string: The version, or an empty string if no version was found.
+func SplitNameVersion(name string) (string, string) {
+	match := archiveVersionPattern.FindStringSubmatch(name)
+	if match == nil {
+		return name, ""
+	}
+	return match[1], match[2]
+}
+
+// CompareVersions compares two dotted version strings segment by segment.
+// Numeric segments are compared numerically and other segments lexically, so
+// "1.10.0" is newer than "1.9.2". A leading "v" is ignored.
+//
+// Parameters:
+//   - a (string): The first version.
+//   - b (string): The second version.
+//
+// Returns:
+//   - int: -1 if a is older than b, 1 if a is newer than b, and 0 if they are equal.
+func CompareVersions(a, b string) int {
+	aParts := versionSegments(a)
+	bParts := versionSegments(b)
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart string
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if c := compareSegment(aPart, bPart); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// versionSegments splits a version into its dot, dash and plus separated segments.
+func versionSegments(version string) []string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	return strings.FieldsFunc(version, func(r rune) bool {
+		return r == '.' || r == '-' || r == '+' || r == '_'
+	})
+}
+
+// compareSegment compares a single version segment; missing segments sort as zero.
+func compareSegment(a, b string) int {
+	if a == "" {
+		a = "0"
+	}
+	if b == "" {
+		b = "0"
+	}
+
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		// A numeric segment is newer than a pre-release tag such as "rc1".
+		return 1
+	case bErr == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}